@@ -0,0 +1,80 @@
+package flags
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// ParseColor parses a CSS color name like "white", a hex color like "#1e1e1e", "#fff" or "#1e1e1e80" with alpha,
+// or "none" for a transparent color.
+func ParseColor(s string) (color.Color, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "none" || s == "transparent" {
+		return color.Transparent, nil
+	}
+	if c, ok := colornames.Map[s]; ok {
+		return c, nil
+	}
+	if !strings.HasPrefix(s, "#") {
+		return nil, fmt.Errorf("unknown color %q, use a name like white or a hex color like #1e1e1e", s)
+	}
+	hex := s[1:]
+	if len(hex) == 3 || len(hex) == 4 {
+		// Short form like #fff
+		var b strings.Builder
+		for _, c := range hex {
+			b.WriteRune(c)
+			b.WriteRune(c)
+		}
+		hex = b.String()
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 8 || err != nil {
+		return nil, fmt.Errorf("invalid hex color %q", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+type colorValue struct {
+	c *color.Color
+}
+
+func (v colorValue) Set(s string) error {
+	c, err := ParseColor(s)
+	if err != nil {
+		return err
+	}
+	*v.c = c
+	return nil
+}
+
+func (v colorValue) String() string {
+	if v.c == nil || *v.c == nil {
+		return ""
+	}
+	c := color.NRGBAModel.Convert(*v.c).(color.NRGBA)
+	if c.A == 0 {
+		return "none"
+	}
+	s := fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	if c.A != 0xff {
+		s += fmt.Sprintf("%02x", c.A)
+	}
+	return s
+}
+
+// Color defines a flag for colors in the formats supported by ParseColor.
+// The color is nil if the flag is not set and value is nil.
+func Color(name string, value color.Color, usage string) *color.Color {
+	c := &value
+	flag.Var(colorValue{c}, name, usage)
+	return c
+}
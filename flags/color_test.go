@@ -0,0 +1,54 @@
+package flags
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestColor(t *testing.T) {
+	tests := []struct {
+		text    string
+		parsed  color.NRGBA
+		str     string
+		invalid bool
+	}{
+		{text: "white", parsed: color.NRGBA{255, 255, 255, 255}, str: "#ffffff"},
+		{text: "DarkSlateGray", parsed: color.NRGBA{47, 79, 79, 255}, str: "#2f4f4f"},
+		{text: "#1e1e1e", parsed: color.NRGBA{30, 30, 30, 255}, str: "#1e1e1e"},
+		{text: "#1E1E1E80", parsed: color.NRGBA{30, 30, 30, 128}, str: "#1e1e1e80"},
+		{text: "#fa0", parsed: color.NRGBA{255, 170, 0, 255}, str: "#ffaa00"},
+		{text: "#fa08", parsed: color.NRGBA{255, 170, 0, 136}, str: "#ffaa0088"},
+		{text: "none", str: "none"},
+		{text: "transparent", str: "none"},
+		{text: "", invalid: true},
+		{text: "nope", invalid: true},
+		{text: "1e1e1e", invalid: true},
+		{text: "#1e1e1", invalid: true},
+		{text: "#ggg", invalid: true},
+	}
+
+	for _, tt := range tests {
+		var c color.Color
+		v := colorValue{&c}
+		if err := v.Set(tt.text); err != nil {
+			if !tt.invalid {
+				t.Errorf("parsing '%s' failed unexpectedly: %v", tt.text, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("parsing '%s' should have failed", tt.text)
+			continue
+		}
+		if n := color.NRGBAModel.Convert(c).(color.NRGBA); n != tt.parsed {
+			t.Errorf("parsing '%s': expected %v, got %v", tt.text, tt.parsed, n)
+		}
+		if s := v.String(); s != tt.str {
+			t.Errorf("formatting '%s': expected %s, got %s", tt.text, tt.str, s)
+		}
+	}
+
+	if s := (colorValue{}).String(); s != "" {
+		t.Errorf("unset color should be empty, got %s", s)
+	}
+}
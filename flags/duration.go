@@ -9,15 +9,24 @@ import (
 
 type durationValue time.Duration
 
-var matchDay = regexp.MustCompile("[-+]?[0-9]*(\\.[0-9]*)?d")
+// Units not supported by time.ParseDuration and their length in hours
+var longUnits = []struct {
+	match *regexp.Regexp
+	hours float64
+}{
+	{match: regexp.MustCompile("[-+]?[0-9]*(\\.[0-9]*)?w"), hours: 7 * 24},
+	{match: regexp.MustCompile("[-+]?[0-9]*(\\.[0-9]*)?d"), hours: 24},
+}
 
 func (d *durationValue) Set(s string) error {
-	if is := matchDay.FindStringIndex(s); is != nil {
-		days, err := strconv.ParseFloat(s[is[0]:is[1]-1], 64)
-		if err != nil {
-			return err
+	for _, u := range longUnits {
+		if is := u.match.FindStringIndex(s); is != nil {
+			n, err := strconv.ParseFloat(s[is[0]:is[1]-1], 64)
+			if err != nil {
+				return err
+			}
+			s = s[:is[0]] + s[is[1]:] + strconv.FormatFloat(n*u.hours, 'f', 6, 64) + "h"
 		}
-		s = s[:is[0]] + s[is[1]:] + strconv.FormatFloat(days*24, 'f', 6, 64) + "h"
 	}
 	v, err := time.ParseDuration(s)
 	if err != nil {
@@ -32,7 +41,7 @@ func (d *durationValue) String() string {
 }
 
 // Duration defines a flag for time.Duration values.
-// It works the same way as flag.Duration except that it als parses "XXd" values as days.
+// It works the same way as flag.Duration except that it also parses "XXd" values as days and "XXw" values as weeks.
 func Duration(name string, value time.Duration, usage string) *time.Duration {
 	t := &value
 	flag.Var((*durationValue)(t), name, usage)
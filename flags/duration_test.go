@@ -21,6 +21,11 @@ func TestDuration(t *testing.T) {
 		{text: "1d1h", parsed: 25 * time.Hour},
 		{text: "1h1d", parsed: 25 * time.Hour},
 		{text: "1h1d60m", parsed: 26 * time.Hour},
+		{text: "1w", parsed: 7 * 24 * time.Hour},
+		{text: "2w3d", parsed: 17 * 24 * time.Hour},
+		{text: "12h1w", parsed: 7*24*time.Hour + 12*time.Hour},
+		{text: "1w3d12h", parsed: 10*24*time.Hour + 12*time.Hour},
+		{text: "w", invalid: true},
 		{text: "", invalid: true},
 		{text: "bla", invalid: true},
 	}
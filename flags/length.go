@@ -0,0 +1,47 @@
+package flags
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/plot/vg"
+)
+
+// Pixels are converted to lengths assuming the default DPI of gonum image canvases
+const pixelsPerInch = 96
+
+type lengthValue vg.Length
+
+func (l *lengthValue) Set(s string) error {
+	if strings.HasSuffix(s, "px") {
+		px, err := strconv.ParseFloat(strings.TrimSuffix(s, "px"), 64)
+		if err != nil {
+			return err
+		}
+		*l = lengthValue(vg.Length(px) * vg.Inch / pixelsPerInch)
+		return nil
+	}
+	v, err := vg.ParseLength(s)
+	if err != nil {
+		return err
+	}
+	*l = lengthValue(v)
+	return nil
+}
+
+func (l *lengthValue) String() string {
+	// Small lengths like line widths are easier to read in points
+	if vg.Length(*l) < vg.Millimeter {
+		return strconv.FormatFloat(float64(*l), 'g', 6, 64) + "pt"
+	}
+	return strconv.FormatFloat(float64(vg.Length(*l)/vg.Centimeter), 'g', 6, 64) + "cm"
+}
+
+// Length defines a flag for vg.Length values.
+// It accepts the units supported by vg.ParseLength (in, cm, mm, pt) and additionally px.
+func Length(name string, value vg.Length, usage string) *vg.Length {
+	l := &value
+	flag.Var((*lengthValue)(l), name, usage)
+	return l
+}
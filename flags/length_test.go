@@ -0,0 +1,68 @@
+package flags
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/plot/vg"
+)
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		text    string
+		parsed  vg.Length
+		invalid bool
+	}{
+		{text: "24cm", parsed: 24 * vg.Centimeter},
+		{text: "6mm", parsed: 6 * vg.Millimeter},
+		{text: "2in", parsed: 2 * vg.Inch},
+		{text: "10pt", parsed: 10},
+		{text: "10", parsed: 10},
+		{text: "96px", parsed: vg.Inch},
+		{text: "800px", parsed: 600},
+		{text: "", invalid: true},
+		{text: "cm", invalid: true},
+		{text: "10km", invalid: true},
+		{text: "px", invalid: true},
+	}
+
+	for i, tt := range tests {
+		var l lengthValue
+		if err := l.Set(tt.text); err != nil {
+			if !tt.invalid {
+				t.Errorf("parsing '%s' failed unexpectedly: %v", tt.text, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("parsing '%s' should have failed", tt.text)
+			continue
+		}
+		if math.Abs(float64(vg.Length(l)-tt.parsed)) > 1e-9 {
+			t.Errorf(`
+%d.
+Input:    %s
+Expected: %v
+Got       %v`, i, tt.text, tt.parsed, vg.Length(l))
+		}
+	}
+}
+
+func TestLengthString(t *testing.T) {
+	tests := []struct {
+		length   vg.Length
+		expected string
+	}{
+		{length: 24 * vg.Centimeter, expected: "24cm"},
+		{length: 3 * vg.Millimeter, expected: "0.3cm"},
+		{length: 1, expected: "1pt"},
+		{length: 0.5, expected: "0.5pt"},
+	}
+
+	for _, tt := range tests {
+		l := lengthValue(tt.length)
+		if s := l.String(); s != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, s)
+		}
+	}
+}
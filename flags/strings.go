@@ -0,0 +1,25 @@
+package flags
+
+import (
+	"flag"
+	"strings"
+)
+
+type stringsValue []string
+
+func (s *stringsValue) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringsValue) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// Strings defines a flag that can be set multiple times.
+// All given values are collected in the order they are passed.
+func Strings(name string, usage string) *[]string {
+	s := &[]string{}
+	flag.Var((*stringsValue)(s), name, usage)
+	return s
+}
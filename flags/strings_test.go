@@ -0,0 +1,33 @@
+package flags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		args   []string
+		parsed []string
+	}{
+		{args: nil, parsed: nil},
+		{args: []string{"up"}, parsed: []string{"up"}},
+		{args: []string{"up", "process_open_fds", "up"}, parsed: []string{"up", "process_open_fds", "up"}},
+	}
+
+	for i, tt := range tests {
+		var s stringsValue
+		for _, a := range tt.args {
+			if err := s.Set(a); err != nil {
+				t.Errorf("setting '%s' failed unexpectedly: %v", a, err)
+			}
+		}
+		if !reflect.DeepEqual([]string(s), tt.parsed) {
+			t.Errorf(`
+%d.
+Input:    %v
+Expected: %v
+Got       %v`, i, tt.args, tt.parsed, s)
+		}
+	}
+}
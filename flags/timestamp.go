@@ -0,0 +1,37 @@
+package flags
+
+import (
+	"flag"
+	"strconv"
+	"time"
+)
+
+type timestamp time.Time
+
+func (t *timestamp) String() string {
+	if (*time.Time)(t).IsZero() {
+		return ""
+	}
+	return (*time.Time)(t).Format(time.RFC3339)
+}
+
+func (t *timestamp) Set(s string) error {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*t = timestamp(time.Unix(sec, 0))
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return err
+	}
+	*t = timestamp(parsed)
+	return nil
+}
+
+// Timestamp defines a flag for time.Time values formatted as RFC3339 or as Unix timestamp in seconds.
+// The time is zero if the flag is not set.
+func Timestamp(name string, usage string) *time.Time {
+	t := &time.Time{}
+	flag.Var((*timestamp)(t), name, usage)
+	return t
+}
@@ -0,0 +1,42 @@
+package flags
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimestamp(t *testing.T) {
+	tests := []struct {
+		text    string
+		parsed  time.Time
+		invalid bool
+	}{
+		{text: "1500000000", parsed: time.Unix(1500000000, 0)},
+		{text: "2017-07-14T02:40:00Z", parsed: time.Unix(1500000000, 0)},
+		{text: "2017-07-14T04:40:00+02:00", parsed: time.Unix(1500000000, 0)},
+		{text: "", invalid: true},
+		{text: "2017-07-14", invalid: true},
+		{text: "1500000000.5", invalid: true},
+	}
+
+	for i, tt := range tests {
+		ts := timestamp{}
+		if err := ts.Set(tt.text); err != nil {
+			if !tt.invalid {
+				t.Errorf("%d. parsing %s failed unexpectedly: %v", i, tt.text, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("%d. parsing %s should have failed", i, tt.text)
+			continue
+		}
+		if !time.Time(ts).Equal(tt.parsed) {
+			t.Errorf(`
+%d.
+Input:    %s
+Expected: %v
+Got       %v`, i, tt.text, tt.parsed, time.Time(ts))
+		}
+	}
+}
@@ -1,10 +1,20 @@
 package flags
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// unixDateOffset is time.UnixDate with a numeric zone offset
+const unixDateOffset = "Mon Jan _2 15:04:05 -0700 2006"
+
+// now is the reference of relative times, replaced in tests
+var now = time.Now
+
 type unixTime time.Time
 
 func (t *unixTime) String() string {
@@ -12,7 +22,7 @@ func (t *unixTime) String() string {
 }
 
 func (t *unixTime) Set(s string) error {
-	parsed, err := time.Parse(time.UnixDate, s)
+	parsed, err := parseTime(s)
 	if err != nil {
 		return err
 	}
@@ -20,7 +30,60 @@ func (t *unixTime) Set(s string) error {
 	return nil
 }
 
-// UnixTime defines a flag for time.Time values formatted as Unix date.
+// parseTime parses "now", a duration relative to now like "-1h", seconds since the Unix epoch,
+// RFC3339 or the Unix date format, whichever matches.
+func parseTime(s string) (time.Time, error) {
+	if s == "now" {
+		return now(), nil
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		// Durations with days and weeks only support a sign at the start of the whole value
+		var d durationValue
+		if err := d.Set(s[1:]); err != nil {
+			return time.Time{}, fmt.Errorf("invalid relative time %q: expected a duration like -30m, -1d or +1h30m", s)
+		}
+		if s[0] == '-' {
+			d = -d
+		}
+		return now().Add(time.Duration(d)), nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, s); err == nil {
+		return parsed, nil
+	}
+	if parsed, err := time.Parse(unixDateOffset, s); err == nil {
+		return parsed, nil
+	}
+	parsed, err := time.Parse(time.UnixDate, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: expected now, a duration relative to now like -1h, seconds since the Unix epoch, RFC3339 like '2006-01-02T15:04:05+01:00' or Unix date like 'Mon Jan 2 15:04:05 CET 2006'", s)
+	}
+	if err := checkZone(parsed); err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: %w", s, err)
+	}
+	return parsed, nil
+}
+
+// checkZone fails for zone abbreviations with an unknown offset.
+// time.Parse only knows the abbreviations of the local time zone and assumes UTC for all others,
+// e.g. CET would silently be off by an hour on a machine using UTC.
+func checkZone(t time.Time) error {
+	if t.Location() == time.UTC || t.Location() == time.Local {
+		return nil
+	}
+	name, offset := t.Zone()
+	if name == "GMT" && offset == 0 {
+		return nil
+	}
+	return errors.New("unknown offset of time zone " + name + ", use a numeric offset like +0100 instead")
+}
+
+// UnixTime defines a flag for time.Time values.
+// Values can be "now", a duration relative to now like "-1h" or "-2d", seconds since the Unix epoch,
+// RFC3339 or formatted as Unix date.
+// Zone abbreviations of Unix dates must be UTC, GMT or known to the local time zone.
 func UnixTime(name string, value time.Time, usage string) *time.Time {
 	t := &value
 	flag.Var((*unixTime)(t), name, usage)
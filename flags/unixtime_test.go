@@ -1,47 +1,91 @@
 package flags
 
 import (
+	"strings"
 	"testing"
 	"time"
+	// Europe/Berlin is also available without system time zone data
+	_ "time/tzdata"
 )
 
 func TestUnixTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reference := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+	defer func(n func() time.Time) { now = n }(now)
+	now = func() time.Time { return reference }
+
 	tests := []struct {
-		text    string
-		parsed  time.Time
-		invalid bool
+		text   string
+		local  *time.Location
+		parsed time.Time
+		err    string
 	}{
-		{text: "Mon Feb 4 10:08:05 CET 2017", parsed: time.Date(2017, 2, 4, 10, 7, 5, 8, time.UTC)},
-		{text: "Tue Feb 4 10:08:05 CET 2017", parsed: time.Date(2017, 2, 4, 10, 7, 5, 8, time.UTC)},
-		{text: "", invalid: true},
-		{text: "Mon Feb 4 10:08:5 CET 2017", invalid: true},
-		{text: "Mon Feb 4 10:8:05 CET 2017", invalid: true},
-		{text: "Mon Feb 04 10:8:05 CET 2017", invalid: true},
-		{text: "Feb 4 10:08:05 CET 2017", invalid: true},
-		{text: "Mon Feb 4 10:08:05 2017", invalid: true},
-		{text: "4 10:08:05 CET 2017", invalid: true},
-		{text: "Mon Feb 4 10:08:05 CET", invalid: true},
+		{text: "now", parsed: reference},
+		{text: "-1h", parsed: reference.Add(-time.Hour)},
+		{text: "-30m", parsed: reference.Add(-30 * time.Minute)},
+		{text: "-1d", parsed: reference.Add(-24 * time.Hour)},
+		{text: "-1d12h", parsed: reference.Add(-36 * time.Hour)},
+		{text: "+1w", parsed: reference.Add(7 * 24 * time.Hour)},
+		{text: "-1x", err: "invalid relative time"},
+		{text: "-", err: "invalid relative time"},
+		{text: "yesterday", err: "expected now, a duration relative to now"},
+		{text: "1700000000", parsed: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)},
+		{text: "0", parsed: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{text: "2023-11-14T22:13:20Z", parsed: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)},
+		{text: "2023-11-14T23:13:20+01:00", parsed: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)},
+		{text: "Sat Feb 4 10:08:05 UTC 2017", parsed: time.Date(2017, 2, 4, 10, 8, 5, 0, time.UTC)},
+		{text: "Sat Feb 4 10:08:05 GMT 2017", parsed: time.Date(2017, 2, 4, 10, 8, 5, 0, time.UTC)},
+		{text: "Sat Feb 4 10:08:05 +0100 2017", parsed: time.Date(2017, 2, 4, 9, 8, 5, 0, time.UTC)},
+		{text: "Sat Feb 14 10:08:05 -0530 2017", parsed: time.Date(2017, 2, 14, 15, 38, 5, 0, time.UTC)},
+		// Abbreviations of the local time zone are known
+		{text: "Sat Feb 4 10:08:05 CET 2017", local: berlin, parsed: time.Date(2017, 2, 4, 9, 8, 5, 0, time.UTC)},
+		{text: "Thu Jun 1 10:08:05 CEST 2017", local: berlin, parsed: time.Date(2017, 6, 1, 8, 8, 5, 0, time.UTC)},
+		{text: "Sat Feb 4 10:08:05 CET 2017", local: time.UTC, err: "unknown offset of time zone CET"},
+		{text: "Sat Feb 4 10:08:05 EST 2017", local: berlin, err: "unknown offset of time zone EST"},
+		{text: "", err: "expected now"},
+		{text: "1.5", err: "expected now"},
+		{text: "2023-11-14 22:13:20", err: "expected now"},
+		{text: "Sat Feb 4 10:08:5 CET 2017", err: "expected now"},
+		{text: "Sat Feb 4 10:8:05 CET 2017", err: "expected now"},
+		{text: "Sat Feb 04 10:8:05 CET 2017", err: "expected now"},
+		{text: "Feb 4 10:08:05 CET 2017", err: "expected now"},
+		{text: "Sat Feb 4 10:08:05 2017", err: "expected now"},
+		{text: "4 10:08:05 CET 2017", err: "expected now"},
+		{text: "Sat Feb 4 10:08:05 CET", err: "expected now"},
 	}
 
-	for i, tt := range tests {
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	for _, tt := range tests {
+		time.Local = tt.local
+		if tt.local == nil {
+			time.Local = time.UTC
+		}
 		u := unixTime{}
-		if err := u.Set(tt.text); err != nil {
-			if !tt.invalid {
-				t.Errorf("parsing %s failed unexpectedly: %v", tt.text, err)
+		err := u.Set(tt.text)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parsing %q: expected error containing %q, got: %v", tt.text, tt.err, err)
 			}
 			continue
 		}
-		if tt.invalid {
-			t.Errorf("parsing %s should have failed", tt.text)
+		if err != nil {
+			t.Errorf("parsing %q failed unexpectedly: %v", tt.text, err)
 			continue
 		}
-		if time.Time(u).Equal(tt.parsed) {
-			t.Errorf(`
-%d.
-Input:    %s
-Expected: %v
-Got       %v`, i, tt.text, tt.parsed, u)
+		if !time.Time(u).Equal(tt.parsed) {
+			t.Errorf("parsing %q: expected %v, got %v", tt.text, tt.parsed, time.Time(u))
+			continue
 		}
-	}
 
+		// Default values are shown formatted with String
+		again := unixTime{}
+		if err := again.Set(u.String()); err != nil {
+			t.Errorf("parsing formatted %q failed: %v", u.String(), err)
+		} else if !time.Time(again).Equal(time.Time(u)) {
+			t.Errorf("round trip of %q: expected %v, got %v", tt.text, time.Time(u), time.Time(again))
+		}
+	}
 }
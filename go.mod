@@ -3,8 +3,14 @@ module qvl.io/promplot
 go 1.15
 
 require (
+	github.com/aws/aws-sdk-go v1.38.0
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
+	github.com/golang/snappy v0.0.0-20180518054509-2e65f85255db
 	github.com/prometheus/client_golang v1.9.0
 	github.com/prometheus/common v0.15.0
-	github.com/slack-go/slack v0.7.4
+	github.com/slack-go/slack v0.15.0
+	golang.org/x/image v0.0.0-20200618115811-c13761719519
 	gonum.org/v1/plot v0.8.1
+	google.golang.org/protobuf v1.23.0
+	gopkg.in/yaml.v2 v2.3.0
 )
@@ -4,13 +4,30 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
 	"qvl.io/promplot/flags"
 	"qvl.io/promplot/promplot"
 )
@@ -24,13 +41,30 @@ Usage: %s [flags...]
 
 Create and deliver plots from your Prometheus metrics.
 
-Save plot to file or send it right to a slack channel.
-One of -slack or -file must be set.
+Save plot to file or S3, send it right to a slack channel, telegram chat, discord or any other webhook, email it or copy it to the clipboard.
+One of -file, -s3-bucket, -slack, -telegram-token, -discord-webhook, -webhook-url, -smtp-host or -clipboard must be set.
 
 
 Flags:
 `
-	more = "\nFor more visit: https://qvl.io/promplot"
+	more = `
+Exit codes:
+  1  invalid flags or any other error
+  2  query to Prometheus failed
+  3  creating the plot failed
+  4  uploading or sending the plot failed
+  5  query returned no data
+
+For more visit: https://qvl.io/promplot`
+)
+
+// Exit codes to distinguish failure causes in scripts, see usage.
+const (
+	exitError  = 1
+	exitQuery  = 2
+	exitPlot   = 3
+	exitUpload = 4
+	exitEmpty  = 5
 )
 
 // Number of data points for the plot
@@ -38,24 +72,140 @@ const step = 100
 
 func main() {
 	var (
+		configFile  = flag.String("config", "", "Optional. YAML or JSON file with flag values, e.g. 'url: http://localhost:9090'. Flags passed on the command line take precedence.")
 		silent      = flag.Bool("silent", false, "Optional. Suppress all output.")
+		verbose     = flag.Bool("verbose", false, "Optional. Log details like request URLs, number of series and timings. Useful for debugging.")
 		versionFlag = flag.Bool("version", false, "Print binary version.")
 		promURL     = flag.String("url", "", "Required. URL of Prometheus server.")
-		query       = flag.String("query", "", "Required. PQL query.")
-		queryTime   = flags.UnixTime("time", time.Now(), "Time for query (default is now). Format like the default format of the Unix date command.")
-		queryRange  = flags.Duration("range", 0, "Required. Time to look back to. Format: 5d12h34m56s")
-		title       = flag.String("title", "Prometheus metrics", "Optional. Title of graph.")
+		queries     = flags.Strings("query", "Required. PQL query. Can be repeated to plot multiple queries in one graph. $__range and $__interval are replaced by the range and step of the query, $__rate_interval by the larger of 4 steps and one step plus -scrape-interval.")
+		queryTime   = flags.UnixTime("time", time.Now(), "Time for query (default is now). Either now, relative to now like -1h or -2d, seconds since the Unix epoch, RFC3339 or the default format of the Unix date command with a numeric offset or a zone abbreviation of the local time zone.")
+		queryRange  = flags.Duration("range", 0, "Required. Time to look back to. Format: 1w5d12h34m56s")
+		title       = flag.String("title", "Prometheus metrics", "Optional. Title of graph. Can contain Go template placeholders {{.Query}}, {{.Range}}, {{.Time}} and {{.Step}}, e.g. '{{.Query}} (resolution: {{.Step}})'.")
+		queryFile   = flag.String("query-file", "", "Optional. Read the PQL query from this file instead of -query, e.g. to keep long queries under version control. Trailing newlines are removed. Variables like $__interval are replaced like in -query.")
+		scrapeInt   = flags.Duration("scrape-interval", promplot.DefaultScrapeInterval, "Optional. Scrape interval of the queried metrics, used to compute $__rate_interval like Grafana.")
+		queryTitle  = flag.Bool("title-from-query", false, "Optional. Use the queries as title unless -title is set. Long queries are shortened.")
+		subtitle    = flag.String("subtitle", "", "Optional. Smaller text below the title, e.g. '{{.Query}}' to show the exact query. Supports the same placeholders as -title. Long text is wrapped.")
+		queryStep   = flags.Duration("step", 0, "Optional. Step between data points of the query. Defaults to range divided into 100 points.")
+		minStep     = flags.Duration("min-step", 0, "Optional. Smallest step between data points, e.g. the scrape interval so that short ranges do not repeat the same values. Applies to -step as well.")
+		roundRange  = flag.Bool("round-range", false, "Optional. Extend the queried time range to round times: 5 minutes for ranges up to 2h, hours up to 2d and days in the time zone of -tz beyond.")
+		stepAlign   = flag.Bool("step-align", false, "Optional. Round start and end of the query down to multiples of the step like Grafana does, so that plots of consecutive runs are comparable.")
+		validate    = flag.Bool("validate", false, "Optional. Only check that Prometheus is reachable, the queries are valid and the output is configured correctly, e.g. before adding promplot to cron. Prints a report and exits non-zero if a check fails.")
+		dryRun      = flag.Bool("dry-run", false, "Optional. Only query Prometheus and print a summary instead of creating and sending the plot.")
+		remoteRead  = flag.Bool("remote-read", false, "Optional. Use the Prometheus remote read protocol, e.g. for long-term storage. -url must be the full endpoint like 'http://localhost:9090/api/v1/read' and -query a series selector like 'up{job=\"node\"}'.")
+		input       = flag.String("input", "", "Optional. Read metrics from a JSON file with a Prometheus query_range or query response instead of querying Prometheus. -url and -query are not required then.")
+		eventsQuery = flag.String("events-query", "", "Optional. PQL query for events like deploys, e.g. 'changes(deploy_timestamp[$__interval])'. Each non-zero point is drawn as a vertical line.")
+		exemplars   = flag.Bool("exemplars", false, "Optional. Draw exemplars of the queries as rings, e.g. to find traces of slow requests. Requires Prometheus 2.26 or later with exemplar storage enabled.")
+		cacheDir    = flag.String("cache-dir", "", "Optional. Directory to cache query results in. Queries are only sent again when their cached result is older than -cache-ttl.")
+		cacheTTL    = flags.Duration("cache-ttl", 5*time.Minute, "Optional. Maximum age of cached query results. 0 keeps them forever.")
+		noCache     = flag.Bool("no-cache", false, "Optional. Ignore cached query results of -cache-dir and refresh them.")
+		dump        = flag.String("dump", "", "Optional. Save the metrics fetched from Prometheus as JSON to this file. Can be read again with -input.")
+		allowEmpty  = flag.Bool("allow-empty", false, "Optional. Create an empty plot instead of failing when the query returns no data.")
+		maxPoints   = flag.Int("max-points", 0, "Optional. Downsample each series to at most this many data points before plotting. Disabled by default.")
+		timeout     = flags.Duration("timeout", 30*time.Second, "Optional. Maximum time to wait for the Prometheus query.")
+		deadline    = flags.Duration("deadline", 0, "Optional. Maximum time for the whole run from checking Slack and querying to uploading the plot, so that scheduled runs never hang. Applies to each update with -watch. Disabled by default.")
+		promUser    = flag.String("prom-user", "", "Optional. Username for HTTP basic auth against Prometheus.")
+		promPass    = flag.String("prom-password", "", "Optional. Password for HTTP basic auth against Prometheus.")
+		promToken   = flag.String("prom-bearer-token", "", "Optional. Bearer token for Prometheus. Cannot be combined with basic auth.")
+		promHeaders = flags.Strings("prom-header", "Optional. HTTP header added to requests to Prometheus in the format Name:Value, e.g. 'X-Scope-OrgID:team'. Can be repeated.")
+		tenant      = flag.String("tenant", "", "Optional. Tenant ID for Grafana Mimir or Cortex. Shorthand for -prom-header X-Scope-OrgID:<tenant>.")
+		caCert      = flag.String("ca-cert", "", "Optional. PEM file with CA certificates to verify the TLS certificate of Prometheus.")
+		clientCert  = flag.String("client-cert", "", "Optional. PEM file with a client certificate for mutual TLS with Prometheus. Requires -client-key.")
+		clientKey   = flag.String("client-key", "", "Optional. PEM file with the private key of -client-cert.")
+		proxy       = flag.String("proxy", "", "Optional. URL of an HTTP or SOCKS5 proxy for requests to Prometheus and Slack, e.g. 'http://proxy:3128' or 'socks5://localhost:1080'. Overrides HTTP_PROXY and HTTPS_PROXY.")
+		redirects   = flag.Int("max-redirects", 10, "Optional. Maximum number of redirects followed for requests to Prometheus. Set to 0 to fail on redirects instead, e.g. to find misconfigured proxies.")
+		insecure    = flag.Bool("insecure-skip-verify", false, "Optional. Do not verify the TLS certificate of Prometheus. WARNING: This makes the connection vulnerable to man-in-the-middle attacks. Only use it for testing.")
 		//
-		format = flag.String("format", "png", "Optional. Image format. For possible values see: https://godoc.org/github.com/gonum/plot/vg/draw#NewFormattedCanvas")
+		format      = flag.String("format", "png", "Optional. Image format. For possible values see: https://godoc.org/github.com/gonum/plot/vg/draw#NewFormattedCanvas. Set to csv to export the raw data instead.")
+		jpegQuality = flag.Int("jpeg-quality", promplot.DefaultJPEGQuality, "Optional. Quality of JPEG images from 1 to 100. Lower quality creates smaller files with more artifacts.")
+		pngLevel    = flag.String("png-compression", "default", "Optional. Compression level of PNG images. One of: "+strings.Join(promplot.PNGCompressions, ", ")+". Better compression creates smaller files but takes longer.")
+		palette     = flag.String("palette", "", "Optional. Name of Brewer color palette. For possible values see: https://godoc.org/gonum.org/v1/plot/palette/brewer. Defaults to "+promplot.DefaultPalette+" or the palette of -theme.")
+		theme       = flag.String("theme", "light", "Optional. Preset of colors and palette. One of: "+strings.Join(promplot.Themes, ", ")+". -bg-color and -palette take precedence.")
+		paletteSize = flag.Int("palette-size", promplot.DefaultPaletteSize, "Optional. Number of colors to use from palette.")
+		legend      = flag.String("legend", "top", "Optional. Position of legend. One of: "+strings.Join(promplot.LegendPositions, ", ")+".")
+		legendFmt   = flag.String("legend-format", "", "Optional. Go template for legend entries using the series labels, e.g. '{{.instance}} ({{.job}})'. Defaults to all labels.")
+		legendMax   = flag.Int("legend-max", 0, "Optional. Maximum number of legend entries. Further series are still drawn and summarized in a last entry. Disabled by default.")
+		keepLabels  = flag.String("keep-labels", "", "Optional. Comma-separated list of labels to keep for each series, e.g. 'job,instance'. All other labels are removed before creating the legend.")
+		dropLabels  = flag.String("drop-labels", "", "Optional. Comma-separated list of labels to remove from each series, e.g. 'instance'.")
+		merge       = flag.Bool("merge", false, "Optional. Sum up series which have the same labels after -keep-labels and -drop-labels instead of plotting them separately.")
+		yLabel      = flag.String("ylabel", "", "Optional. Label of Y axis.")
+		yUnit       = flag.String("yunit", "", "Optional. Unit for formatting Y axis values. One of: "+strings.Join(promplot.Units, ", ")+".")
+		sortBy      = flag.String("sort-by", "", "Optional. Sort series descending by an aggregate of their values. One of: "+strings.Join(promplot.Aggregates, ", ")+". Defaults to sorting by labels.")
+		topN        = flag.Int("top-n", 0, "Optional. Only plot the N series with the highest -sort-by aggregate, max by default. Disabled by default.")
+		topNOther   = flag.Bool("top-n-other", false, "Optional. Sum up the series dropped by -top-n into a single \"other\" series.")
+		aggregateBy = flag.String("aggregate", "", "Optional. Combine all series into a single one. One of: "+strings.Join(promplot.SeriesAggregates, ", ")+". Series without a value at a time are skipped instead of counting as zero.")
+		xMin        = flags.Timestamp("xmin", "Optional. Start of the visible time range as RFC3339 or Unix timestamp. Defaults to the start of the data.")
+		xMax        = flags.Timestamp("xmax", "Optional. End of the visible time range as RFC3339 or Unix timestamp. Defaults to the end of the data.")
+		yMinFlag    = flag.String("ymin", "", "Optional. Minimum of Y axis. Defaults to the minimum of the data.")
+		yMaxFlag    = flag.String("ymax", "", "Optional. Maximum of Y axis. Defaults to the maximum of the data.")
+		heatmap     = flag.Bool("heatmap", false, "Optional. Draw histogram buckets as heatmap instead of lines. The query must return the bucket series with the le label, e.g. 'sum by (le) (rate(http_request_duration_seconds_bucket[5m]))'.")
+		logY        = flag.Bool("log-y", false, "Optional. Use a logarithmic scale for the Y axis. All values must be positive, unless they are below -ymin.")
+		grid        = flag.Bool("grid", false, "Optional. Draw grid lines.")
+		timeFormat  = flag.String("time-format", "", "Optional. Go time layout of X axis labels, e.g. '15:04:05' or 'Jan 02'. Defaults to a format based on -range.")
+		freshness   = flag.Bool("show-freshness", false, "Optional. Add a footer with the time of the newest data point, e.g. 'data as of 2024-01-02 15:04 UTC', so viewers can tell how old the data is. Uses the time zone of -tz.")
+		timeZone    = flag.String("tz", "UTC", "Optional. Time zone of X axis labels, e.g. 'America/New_York' or 'Local'.")
+		width       = flags.Length("width", promplot.DefaultWidth, "Optional. Width of image. Supported units: in, cm, mm, pt, px.")
+		height      = flags.Length("height", promplot.DefaultHeight, "Optional. Height of image. Supported units: in, cm, mm, pt, px.")
+		background  = flags.Color("bg-color", nil, "Optional. Background color of the whole image as name or hex value, e.g. 'white' or '#1e1e1e'. 'none' is transparent. Defaults to white with a transparent margin for vector formats like SVG.")
+		margin      = flags.Length("margin", promplot.DefaultMargin, "Optional. Margin around plot. Supported units: in, cm, mm, pt, px.")
+		font        = flag.String("font", promplot.DefaultFont, "Optional. Font of all text. One of: "+strings.Join(promplot.Fonts(), ", ")+".")
+		titleSize   = flags.Length("title-font-size", promplot.DefaultTitleFontSize, "Optional. Font size of title. Supported units: in, cm, mm, pt, px.")
+		titleAlign  = flag.String("title-align", "center", "Optional. Horizontal alignment of title and subtitle. One of: "+strings.Join(promplot.TitleAligns, ", ")+".")
+		noTitle     = flag.Bool("no-title", false, "Optional. Leave the title out of the plot so the data fills its space, e.g. for dashboards with their own header. The title is still used for messages and metadata.")
+		titlePad    = flags.Length("title-padding", promplot.DefaultTitlePadding, "Optional. Space between title and data. Supported units: in, cm, mm, pt, px.")
+		embedFonts  = flag.Bool("embed-fonts", false, "Optional. Draw the text of SVG plots as outlines instead of referring to the font by name, so that they look the same on systems without the font. The text can then not be selected.")
+		fontFile    = flag.String("font-file", "", "Optional. TrueType font file to use for all text. Registered under the -font name if given, otherwise under its file name.")
+		tickSize    = flags.Length("tick-font-size", promplot.DefaultTickFontSize, "Optional. Font size of axis ticks, labels and legend. Supported units: in, cm, mm, pt, px.")
+		smooth      = flag.String("smooth", "", "Optional. Window of moving average applied to each series. Either a number of points, e.g. '5', or a duration, e.g. '10m'.")
+		style       = flag.String("style", "line", "Optional. How to draw each series. One of: "+strings.Join(promplot.Styles, ", ")+". Points are useful for sparse data where lines between samples are misleading.")
+		lineWidth   = flags.Length("line-width", promplot.DefaultLineWidth, "Optional. Width of the line of each series. Supported units: in, cm, mm, pt, px.")
+		dash        = flag.Bool("dash", false, "Optional. Draw each series with a different dash pattern in addition to its color, e.g. for printing in grayscale.")
+		gaps        = flag.String("gap-threshold", "auto", "Optional. Points further apart than this duration are not connected, e.g. '5m', to show missing data as gaps. 'auto' uses three times the usual distance between points, 'none' connects all points.")
+		fillUnder   = flag.Bool("fill-under", false, "Optional. Shade the area below each line. Works best with a single series.")
+		hLineFlags  = flags.Strings("hline", "Optional. Horizontal line at a Y value in the format value[:label], e.g. '0.999:SLO'. Can be repeated.")
+		vLineFlags  = flags.Strings("vline", "Optional. Vertical line at a point in time in the format RFC3339[:label], e.g. '2006-01-02T15:04:05Z:deploy'. Can be repeated.")
+		smoothRaw   = flag.Bool("smooth-overlay", false, "Optional. Draw the raw data below the smoothed lines when -smooth is set.")
 	)
 
 	var (
-		file = flag.String("file", "", "File to save image to. Should have same extension as specified -format. Set -file to - to write to stdout.")
+		file         = flag.String("file", "", "File to save image to. Should have same extension as specified -format. Set -file to - to write to stdout. Multiple comma-separated files can be given, the format of each is inferred from its extension. Files ending in .svgz are gzip compressed SVG.")
+		gzipOut      = flag.Bool("gzip", false, "Optional. Gzip compress the output written with -file.")
+		serve        = flag.String("serve", "", "Optional. Address to serve plots over HTTP on, e.g. ':8080'. Requests to /plot create a new plot. The parameters query, range and format override the flags.")
+		serveTimeout = flags.Duration("serve-timeout", time.Minute, "Optional. Maximum time to handle a request with -serve.")
+		watch        = flags.Duration("watch", 0, "Optional. Keep running and render the plot again after this interval, e.g. '1m'. The file is replaced atomically. Stops on SIGINT or SIGTERM.")
 	)
 
 	var (
 		slackToken = flag.String("slack", "", "Slack API token (https://api.slack.com/docs/oauth-test-tokens). Set to post plot to Slack.")
-		channel    = flag.String("channel", "", "Required when -slack is set. Slack channel to post to.")
+		channel    = flag.String("channel", "", "Required when -slack is set. Slack channel name or ID to post to. Multiple comma-separated channels can be given. Names are looked up before querying, which requires the channels:read scope.")
+		retries    = flag.Int("slack-retries", 3, "Optional. Maximum number of retries when Slack is rate limiting or unavailable.")
+		slackMsg   = flag.String("slack-message", "", "Optional. Text posted together with the plot. Supports Slack formatting like *bold* and <https://example.com|links>.")
+		threadTS   = flag.String("slack-thread-ts", "", "Optional. Timestamp of a Slack message to post the plot as a threaded reply to.")
+	)
+
+	var (
+		smtpHost = flag.String("smtp-host", "", "SMTP server to send plot by email. Set to email plot.")
+		smtpPort = flag.Int("smtp-port", 587, "Optional. Port of SMTP server.")
+		smtpUser = flag.String("smtp-user", "", "Optional. Username for SMTP authentication.")
+		smtpPass = flag.String("smtp-password", "", "Optional. Password for SMTP authentication.")
+		smtpFrom = flag.String("smtp-from", "", "Required when -smtp-host is set. Sender address of email.")
+		smtpTo   = flag.String("smtp-to", "", "Required when -smtp-host is set. Comma-separated list of recipient addresses.")
+	)
+
+	var (
+		telegramToken = flag.String("telegram-token", "", "Telegram bot token. Set to send plot to Telegram.")
+		telegramChat  = flag.String("telegram-chat", "", "Required when -telegram-token is set. Telegram chat ID to send to.")
+		//
+		s3Bucket = flag.String("s3-bucket", "", "S3 bucket to upload plot to. Set to upload plot to S3. Credentials are loaded from the standard AWS credential chain like AWS_ACCESS_KEY_ID or ~/.aws/credentials.")
+		s3Key    = flag.String("s3-key", "", "Required when -s3-bucket is set. Key of the uploaded object, e.g. 'plots/{{.Time.Format \"2006-01-02\"}}/up.png'. Supports the same placeholders as -title. Its extension determines the Content-Type.")
+		s3Region = flag.String("s3-region", "", "Optional. AWS region of -s3-bucket. Defaults to the region of the AWS configuration.")
+		//
+		discordHook = flag.String("discord-webhook", "", "Discord webhook URL (https://support.discord.com/hc/en-us/articles/228383668). Set to post plot to the channel of the webhook.")
+		//
+		webhookURL       = flag.String("webhook-url", "", "URL to POST the plot to. Set to send plot to any HTTP endpoint. The plot is the request body and the title is sent in the "+promplot.TitleHeader+" header.")
+		webhookHeaders   = flags.Strings("webhook-header", "Optional. HTTP header added to requests to -webhook-url in the format Name:Value, e.g. 'Authorization:Bearer token'. Can be repeated. A Content-Type header overrides the type of the plot.")
+		webhookMultipart = flag.Bool("webhook-multipart", false, "Optional. Send a multipart form with field 'title' and the plot as file field 'file' to -webhook-url instead.")
+		//
+		clipboard = flag.Bool("clipboard", false, "Copy the plot to the system clipboard. Requires -format png and wl-copy or xclip on Linux.")
 	)
 
 	flag.Usage = func() {
@@ -70,72 +220,1402 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *configFile != "" {
+		if err := loadConfig(*configFile); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Required flags
 	var errs []string
-	if *promURL == "" {
+	if *promURL == "" && *input == "" {
 		errs = append(errs, "missing flag: -url")
 	}
-	if *query == "" {
-		errs = append(errs, "missing flag: -query")
+	if *queryFile != "" {
+		if len(*queries) > 0 {
+			errs = append(errs, "only one of -query or -query-file can be set")
+		} else if q, err := readQuery(*queryFile); err != nil {
+			errs = append(errs, fmt.Sprintf("failed to read -query-file: %v", err))
+		} else {
+			*queries = []string{q}
+		}
+	}
+	if len(*queries) == 0 && *input == "" && *queryFile == "" {
+		errs = append(errs, "missing flag: -query or -query-file")
 	}
 	if *queryRange == 0 {
 		errs = append(errs, "missing flag: -range")
 	}
-	if *file == "" && *slackToken == "" {
-		errs = append(errs, "one of -file or -slack must be set")
-	} else if *file != "" && *slackToken != "" {
-		errs = append(errs, "only one of -file or -slack can be set")
-	} else if *slackToken != "" && *channel == "" {
+	// Placeholders are expanded again for each request of the HTTP server
+	// The warning is logged after validating all flags
+	location, locationErr := time.LoadLocation(*timeZone)
+	if locationErr != nil {
+		location = time.UTC
+	}
+
+	newRequest := func(queryTime time.Time, queries []string, queryRange time.Duration, format string) (plotRequest, []string) {
+		var errs []string
+		if *roundRange {
+			queryTime, queryRange = roundTimeRange(queryTime, queryRange, location)
+		}
+		req := plotRequest{
+			time:       queryTime,
+			queryRange: queryRange,
+			step:       promplot.QueryStepWithMin(queryRange, step, *queryStep, *minStep),
+			format:     format,
+			timeFormat: *timeFormat,
+		}
+		for _, q := range queries {
+			expanded, err := promplot.ExpandQueryWithScrapeInterval(q, queryRange, req.step, *scrapeInt)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("invalid -query %q: %v", q, err))
+			}
+			req.queries = append(req.queries, expanded)
+		}
+		if *eventsQuery != "" {
+			expanded, err := promplot.ExpandQueryWithScrapeInterval(*eventsQuery, queryRange, req.step, *scrapeInt)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("invalid -events-query %q: %v", *eventsQuery, err))
+			}
+			req.eventsQuery = expanded
+		}
+		titleData := promplot.TitleData{
+			Query: strings.Join(req.queries, ", "),
+			Range: model.Duration(queryRange),
+			Time:  queryTime,
+			Step:  model.Duration(req.step),
+		}
+		title, err := promplot.ExpandTitle(*title, titleData)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid -title: %v", err))
+		}
+		req.title = title
+		if *queryTitle && !isSet("title") && len(req.queries) > 0 {
+			// Not expanded as template since queries can contain braces
+			req.title = shorten(strings.Join(strings.Fields(titleData.Query), " "), maxQueryTitle)
+		}
+		subtitle, err := promplot.ExpandTitle(*subtitle, titleData)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid -subtitle: %v", err))
+		}
+		req.subtitle = subtitle
+		s3Key, err := promplot.ExpandTitle(*s3Key, titleData)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid -s3-key: %v", err))
+		}
+		req.s3Key = s3Key
+		if format != "csv" {
+			if err := promplot.ValidFormat(format); err != nil {
+				errs = append(errs, fmt.Sprintf("invalid -format: %v", err))
+			}
+		}
+		if req.timeFormat == "" {
+			req.timeFormat = promplot.TimeFormatFor(queryRange)
+		}
+		return req, errs
+	}
+	req, reqErrs := newRequest(*queryTime, *queries, *queryRange, *format)
+	errs = append(errs, reqErrs...)
+	if *eventsQuery != "" && *input != "" {
+		errs = append(errs, "-events-query cannot be used with -input")
+	}
+	if *eventsQuery != "" && *remoteRead {
+		errs = append(errs, "-events-query cannot be used with -remote-read")
+	}
+	if *exemplars && (*input != "" || *remoteRead) {
+		errs = append(errs, "-exemplars cannot be used with -input or -remote-read")
+	}
+	if *timeFormat != "" {
+		if err := promplot.ValidTimeFormat(*timeFormat); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid -time-format: %v", err))
+		}
+	}
+	if *queryStep < 0 {
+		errs = append(errs, "-step cannot be negative")
+	}
+	if *minStep < 0 {
+		errs = append(errs, "-min-step cannot be negative")
+	}
+	if *jpegQuality < 1 || *jpegQuality > 100 {
+		errs = append(errs, "-jpeg-quality must be between 1 and 100")
+	}
+	if *legendMax < 0 {
+		errs = append(errs, "-legend-max cannot be negative")
+	}
+	if *topN < 0 {
+		errs = append(errs, "-top-n cannot be negative")
+	}
+	if *topNOther && *topN == 0 {
+		errs = append(errs, "-top-n-other requires -top-n")
+	}
+	if *aggregateBy != "" && *topN > 0 {
+		errs = append(errs, "only one of -aggregate or -top-n can be set")
+	}
+	if *heatmap && (*aggregateBy != "" || *topN > 0 || *logY || *yMinFlag != "" || *yMaxFlag != "") {
+		errs = append(errs, "-heatmap cannot be used with -aggregate, -top-n, -log-y, -ymin or -ymax")
+	}
+	if *cacheTTL < 0 {
+		errs = append(errs, "-cache-ttl cannot be negative")
+	}
+	if *noCache && *cacheDir == "" {
+		errs = append(errs, "-no-cache requires -cache-dir")
+	}
+	if *maxPoints != 0 && *maxPoints < 3 {
+		errs = append(errs, "-max-points must be at least 3")
+	}
+	if *timeout <= 0 {
+		errs = append(errs, "-timeout must be positive")
+	}
+	if *scrapeInt <= 0 {
+		errs = append(errs, "-scrape-interval must be positive")
+	}
+	if *deadline < 0 {
+		errs = append(errs, "-deadline must not be negative")
+	}
+	if *width <= 0 || *height <= 0 || *margin <= 0 {
+		errs = append(errs, "-width, -height and -margin must be positive")
+	}
+	if *lineWidth <= 0 {
+		errs = append(errs, "-line-width must be positive")
+	}
+	if *titleSize <= 0 || *tickSize <= 0 {
+		errs = append(errs, "-title-font-size and -tick-font-size must be positive")
+	}
+	var gapThreshold time.Duration
+	switch *gaps {
+	case "auto":
+	case "none":
+		gapThreshold = -1
+	default:
+		d, err := time.ParseDuration(*gaps)
+		if err != nil || d <= 0 {
+			errs = append(errs, fmt.Sprintf("invalid -gap-threshold %q: must be a positive duration, auto or none", *gaps))
+		}
+		gapThreshold = d
+	}
+	var smoothPoints int
+	var smoothWindow time.Duration
+	if *smooth != "" {
+		if n, err := strconv.Atoi(*smooth); err == nil {
+			smoothPoints = n
+		} else if d, err := time.ParseDuration(*smooth); err == nil {
+			smoothWindow = d
+		}
+		if smoothPoints <= 0 && smoothWindow <= 0 {
+			errs = append(errs, fmt.Sprintf("invalid -smooth %q: must be a positive number of points or a duration", *smooth))
+		}
+	}
+	if !xMin.IsZero() && !xMax.IsZero() && !xMin.Before(*xMax) {
+		errs = append(errs, "-xmin must be before -xmax")
+	}
+	var yMin, yMax *float64
+	for _, b := range []struct {
+		flag  string
+		value string
+		bound **float64
+	}{{"-ymin", *yMinFlag, &yMin}, {"-ymax", *yMaxFlag, &yMax}} {
+		if b.value == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(b.value, 64)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid %s %q: must be a number", b.flag, b.value))
+			continue
+		}
+		*b.bound = &v
+	}
+	if yMin != nil && yMax != nil && *yMin >= *yMax {
+		errs = append(errs, "-ymin must be smaller than -ymax")
+	}
+	var hLines []promplot.HLine
+	for _, h := range *hLineFlags {
+		l, err := promplot.ParseHLine(h)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid -hline: %v", err))
+			continue
+		}
+		hLines = append(hLines, l)
+	}
+	var vLines []promplot.VLine
+	for _, v := range *vLineFlags {
+		l, err := promplot.ParseVLine(v)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid -vline: %v", err))
+			continue
+		}
+		vLines = append(vLines, l)
+	}
+	headers, headerErrs := parseHeaders("-prom-header", *promHeaders)
+	errs = append(errs, headerErrs...)
+	hookHeaders, headerErrs := parseHeaders("-webhook-header", *webhookHeaders)
+	errs = append(errs, headerErrs...)
+	var proxyURL *url.URL
+	if *proxy != "" {
+		u, err := promplot.ParseProxy(*proxy)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid -proxy: %v", err))
+		}
+		proxyURL = u
+	}
+	if *webhookURL == "" && (len(hookHeaders) > 0 || *webhookMultipart) {
+		errs = append(errs, "-webhook-header and -webhook-multipart require -webhook-url")
+	}
+	if *clipboard && *format != "png" {
+		errs = append(errs, "-clipboard requires -format png")
+	}
+	if *tenant != "" && headers.Get(promplot.TenantHeader) != "" {
+		errs = append(errs, "only one of -tenant or -prom-header "+promplot.TenantHeader+" can be set")
+	}
+	if (*clientCert == "") != (*clientKey == "") {
+		errs = append(errs, "-client-cert and -client-key must be set together")
+	}
+	if *merge && *keepLabels == "" && *dropLabels == "" {
+		errs = append(errs, "-merge requires -keep-labels or -drop-labels")
+	}
+	if *silent && *verbose {
+		errs = append(errs, "only one of -silent or -verbose can be set")
+	}
+	if *redirects < 0 {
+		errs = append(errs, "-max-redirects cannot be negative")
+	}
+	if *caCert != "" && *insecure {
+		errs = append(errs, "only one of -ca-cert or -insecure-skip-verify can be set")
+	}
+	if (*promUser != "" || *promPass != "") && *promToken != "" {
+		errs = append(errs, "only one of -prom-user/-prom-password or -prom-bearer-token can be set")
+	}
+	outputs := []struct {
+		flag string
+		set  bool
+	}{
+		{"-file", *file != ""},
+		{"-s3-bucket", *s3Bucket != ""},
+		{"-slack", *slackToken != ""},
+		{"-telegram-token", *telegramToken != ""},
+		{"-discord-webhook", *discordHook != ""},
+		{"-webhook-url", *webhookURL != ""},
+		{"-smtp-host", *smtpHost != ""},
+		{"-clipboard", *clipboard},
+	}
+	var outputFlags, setOutputs []string
+	for _, o := range outputs {
+		outputFlags = append(outputFlags, o.flag)
+		if o.set {
+			setOutputs = append(setOutputs, o.flag)
+		}
+	}
+	if *validate && (*serve != "" || *watch > 0 || *dryRun) {
+		errs = append(errs, "-validate cannot be used with -serve, -watch or -dry-run")
+	}
+	if *serve != "" {
+		if len(setOutputs) > 0 || *dump != "" {
+			errs = append(errs, "-serve cannot be used with "+strings.Join(append(outputFlags, "-dump"), ", "))
+		}
+		if *watch > 0 || *dryRun {
+			errs = append(errs, "-serve cannot be used with -watch or -dry-run")
+		}
+		if *serveTimeout <= 0 {
+			errs = append(errs, "-serve-timeout must be positive")
+		}
+		if *deadline > 0 {
+			errs = append(errs, "-deadline cannot be used with -serve, use -serve-timeout instead")
+		}
+	} else if len(setOutputs) == 0 && *dump == "" {
+		errs = append(errs, "one of "+strings.Join(append(outputFlags, "-dump"), ", ")+" must be set")
+	} else if len(setOutputs) > 1 {
+		errs = append(errs, "only one of "+strings.Join(setOutputs, ", ")+" can be set")
+	}
+	var files []string
+	if *file != "" {
+		files = strings.Split(*file, ",")
+	}
+	if len(files) > 1 {
+		for _, f := range files {
+			if f == "-" {
+				errs = append(errs, "-file cannot write to stdout when multiple files are given")
+			} else if ext := fileFormat(f); ext != "csv" && promplot.ValidFormat(ext) != nil {
+				errs = append(errs, fmt.Sprintf("unsupported file extension of '%s', valid formats are: csv, %s", f, strings.Join(promplot.Formats, ", ")))
+			}
+		}
+	}
+	svg := *format == "svg"
+	if len(files) > 1 {
+		svg = false
+		for _, f := range files {
+			svg = svg || fileFormat(f) == "svg"
+		}
+	}
+	if *embedFonts && !svg {
+		errs = append(errs, "-embed-fonts requires -format svg")
+	}
+	if *slackToken != "" && len(promplot.SlackChannels(*channel)) == 0 {
 		errs = append(errs, "missing flag: -channel")
 	}
+	if *s3Bucket != "" && *s3Key == "" {
+		errs = append(errs, "missing flag: -s3-key")
+	}
+	if *telegramToken != "" && *telegramChat == "" {
+		errs = append(errs, "missing flag: -telegram-chat")
+	}
+	if *smtpHost != "" && *smtpFrom == "" {
+		errs = append(errs, "missing flag: -smtp-from")
+	}
+	if *smtpHost != "" && *smtpTo == "" {
+		errs = append(errs, "missing flag: -smtp-to")
+	}
+	if *retries < 0 {
+		errs = append(errs, "-slack-retries cannot be negative")
+	}
+	if *watch < 0 {
+		errs = append(errs, "-watch cannot be negative")
+	}
+	if *watch > 0 {
+		if *file == "" || *file == "-" {
+			errs = append(errs, "-watch requires -file with a file name")
+		}
+		if isSet("time") {
+			errs = append(errs, "-watch cannot be used with -time, each run ends now")
+		}
+		if *dryRun {
+			errs = append(errs, "-watch cannot be used with -dry-run")
+		}
+	}
 	if len(errs) > 0 {
 		fmt.Fprintf(os.Stderr, strings.Join(errs, "\n")+"\n\nFor more info see %s -h\n", os.Args[0])
 		os.Exit(1)
 	}
 
 	// Logging helper
-	log := func(format string, a ...interface{}) {
-		if !*silent {
-			fmt.Fprintf(os.Stderr, format+"\n", a...)
+	l := logger{level: levelNormal, w: os.Stderr}
+	if *silent {
+		l.level = levelOff
+	} else if *verbose {
+		l.level = levelVerbose
+	}
+	log, debug := l.log, l.debug
+
+	if len(files) == 1 && *file != "-" {
+		if ext := fileFormat(*file); !sameFormat(ext, *format) {
+			log("Warning: extension of file '%s' does not match format %q", *file, *format)
 		}
 	}
 
-	// Fetch from Prometheus
-	log("Querying Prometheus %q", *query)
-	metrics, err := promplot.Metrics(*promURL, *query, *queryTime, *queryRange, step)
-	fatal(err, "failed to get metrics")
+	if *s3Bucket != "" {
+		if ext := fileFormat(req.s3Key); !sameFormat(ext, *format) {
+			log("Warning: extension of -s3-key '%s' does not match format %q", req.s3Key, *format)
+		}
+	}
 
-	// Plot
-	log("Creating plot %q", *title)
-	plot, err := promplot.Plot(metrics, *title, *format)
-	fatal(err, "failed to create plot")
+	if locationErr != nil {
+		log("Warning: failed to load time zone %q, using UTC: %v", *timeZone, locationErr)
+	}
 
-	// Write to file
-	if *file != "" {
-		var out *os.File
-		if *file == "-" {
-			log("Writing to stdout")
-			out = os.Stdout
+	if *input == "" && !*remoteRead {
+		for _, q := range *queries {
+			if c := rawCounter(q); c != "" {
+				log("Warning: query '%s' plots the counter %s as an ever increasing line, did you mean 'rate(%s[$__interval])'?", q, c, c)
+			}
+		}
+	}
+
+	var tlsConfig *tls.Config
+	if *input == "" {
+		if *caCert != "" || *clientCert != "" || *insecure {
+			tlsConfig = &tls.Config{InsecureSkipVerify: *insecure}
+		}
+		if *caCert != "" {
+			pool, err := promplot.LoadCertPool(*caCert)
+			fatal(err, "failed to load CA certificate")
+			tlsConfig.RootCAs = pool
+		}
+		if *clientCert != "" {
+			cert, err := promplot.LoadKeyPair(*clientCert, *clientKey)
+			fatal(err, "failed to load client certificate")
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		if *insecure {
+			log("Warning: skipping TLS certificate verification")
+		}
+	}
+	cfg := promplot.MetricsConfig{
+		Username:     *promUser,
+		Password:     *promPass,
+		BearerToken:  *promToken,
+		Step:         *queryStep,
+		MinStep:      *minStep,
+		TLSConfig:    tlsConfig,
+		Headers:      headers,
+		Tenant:       *tenant,
+		AlignStep:    *stepAlign,
+		Proxy:        proxyURL,
+		MaxRedirects: *redirects,
+		Logf:         debug,
+	}
+	if *redirects == 0 {
+		// Zero keeps the default in the library
+		cfg.MaxRedirects = -1
+	}
+
+	if *fontFile != "" {
+		name := *font
+		if !isSet("font") {
+			name = strings.TrimSuffix(filepath.Base(*fontFile), filepath.Ext(*fontFile))
+		}
+		debug("Registering font '%s' as %q", *fontFile, name)
+		fatal(registerFont(name, *fontFile), "failed to load font file")
+		*font = name
+	}
+
+	// newContext bounds a single run from the Slack check to the upload by -deadline
+	newContext := func() (context.Context, context.CancelFunc) {
+		if *deadline > 0 {
+			return context.WithTimeout(context.Background(), *deadline)
+		}
+		return context.WithCancel(context.Background())
+	}
+	ctx, cancel := newContext()
+	defer cancel()
+
+	// Check the configuration without creating a plot
+	if *validate {
+		code := 0
+		check := func(name string, err error) {
+			if err == nil {
+				fmt.Printf("OK    %s\n", name)
+				return
+			}
+			fmt.Printf("FAIL  %s: %v\n", name, err)
+			if code == 0 {
+				code = exitCode(err)
+			}
+		}
+		queryCtx, cancel := context.WithTimeout(ctx, *timeout)
+		defer cancel()
+		switch {
+		case *input != "":
+			_, err := readMatrix(*input)
+			check(fmt.Sprintf("reading '%s'", *input), err)
+		case *remoteRead:
+			for _, q := range req.queries {
+				_, err := promplot.MetricsRemoteRead(queryCtx, *promURL, q, req.time, req.queryRange, step, cfg)
+				if errors.Is(err, promplot.ErrEmptyResult) {
+					err = nil
+				}
+				check(fmt.Sprintf("remote read %q", q), err)
+			}
+		default:
+			for _, q := range append(req.queries, req.eventsQuery) {
+				if q != "" {
+					check(fmt.Sprintf("query %q", q), promplot.CheckQuery(queryCtx, *promURL, q, req.time, cfg))
+				}
+			}
+		}
+		for _, f := range files {
+			if f != "-" {
+				check(fmt.Sprintf("writing to '%s'", f), checkWritable(f))
+			}
+		}
+		if *slackToken != "" {
+			_, err := promplot.ResolveSlackChannels(ctx, *slackToken, *channel, promplot.SlackConfig{Retries: *retries, Proxy: proxyURL})
+			check("Slack token and channels", err)
+		}
+		for _, o := range setOutputs {
+			if o != "-file" && o != "-slack" {
+				fmt.Printf("SKIP  %s is not checked\n", o)
+			}
+		}
+		os.Exit(code)
+	}
+
+	// A wrong Slack setup fails before querying Prometheus, the resolved IDs are used for all uploads
+	if *slackToken != "" {
+		debug("Checking Slack token and channels")
+		ids, err := promplot.ResolveSlackChannels(ctx, *slackToken, *channel, promplot.SlackConfig{Retries: *retries, Proxy: proxyURL})
+		fatal(err, "failed to check Slack")
+		*channel = strings.Join(ids, ",")
+	}
+
+	// run fetches the data of req, creates the plot and delivers it.
+	// If w is set, the plot is written to it instead of the configured output.
+	run := func(ctx context.Context, req plotRequest, w io.Writer) error {
+		// Fetch from Prometheus or read from file
+		var metrics model.Matrix
+		var err error
+		vLines := vLines
+		var exemplarPoints []promplot.Exemplar
+		start := time.Now()
+		if *input != "" {
+			log("Reading metrics from '%s'", *input)
+			metrics, err = readMatrix(*input)
+			if err == nil {
+				err = promplot.CheckEmpty(metrics)
+			}
 		} else {
-			log("Writing to '%s'", *file)
-			out, err = os.Create(*file)
-			fatal(err, "failed to create file")
+			// -round-range can extend the range of each request
+			requestedStep := *queryStep
+			if requestedStep == 0 {
+				requestedStep = req.queryRange / step
+			}
+			if requestedStep != req.step {
+				reason := "to stay within Prometheus resolution limits"
+				if req.step == *minStep {
+					reason = "to match -min-step"
+				}
+				log("Adjusted step from %v to %v %s", requestedStep, req.step, reason)
+			}
+			debug("Using step %v for range %v ending at %s", req.step, req.queryRange, req.time.Format(time.RFC3339))
+			ctx, cancel := context.WithTimeout(ctx, *timeout)
+			defer cancel()
+			// Results for the default time of now are reused until they exceed the TTL
+			cacheTime := "now"
+			if isSet("time") {
+				cacheTime = req.time.UTC().Format(time.RFC3339)
+			}
+			cached := func(queries []string, fetch func() (model.Matrix, error)) (model.Matrix, error) {
+				if *cacheDir == "" {
+					return fetch()
+				}
+				cache := promplot.Cache{Dir: *cacheDir, TTL: *cacheTTL}
+				// Credentials can change the visible data, they only end up in the key as hash
+				var headers strings.Builder
+				cfg.Headers.Write(&headers)
+				auth := promplot.CacheKey(cfg.Username, cfg.Password, cfg.BearerToken, *clientCert, cfg.Tenant, headers.String())
+				parts := []string{*promURL, strconv.FormatBool(*remoteRead), auth, cacheTime, req.queryRange.String(), req.step.String(), cfg.MinStep.String(), strconv.FormatBool(cfg.AlignStep)}
+				key := promplot.CacheKey(append(parts, queries...)...)
+				if m, ok := cache.Get(key); ok && !*noCache {
+					log("Using cached result of %q", strings.Join(queries, ", "))
+					return m, promplot.CheckEmpty(m)
+				}
+				m, err := fetch()
+				if err == nil {
+					debug("Caching result in '%s'", *cacheDir)
+					if err := cache.Put(key, m); err != nil {
+						log("Warning: %v", err)
+					}
+				}
+				return m, err
+			}
+			metrics, err = cached(req.queries, func() (model.Matrix, error) {
+				for _, q := range req.queries {
+					log("Querying Prometheus %q", q)
+				}
+				if *remoteRead {
+					return remoteReadQueries(ctx, *promURL, req.queries, req.time, req.queryRange, cfg)
+				}
+				return promplot.MetricsQueries(ctx, *promURL, req.queries, req.time, req.queryRange, step, cfg)
+			})
+			if req.eventsQuery != "" && (err == nil || errors.Is(err, promplot.ErrEmptyResult)) {
+				events, err := cached([]string{"events", req.eventsQuery}, func() (model.Matrix, error) {
+					log("Querying events %q", req.eventsQuery)
+					return promplot.MetricsWithConfig(ctx, *promURL, req.eventsQuery, req.time, req.queryRange, step, cfg)
+				})
+				if errors.Is(err, promplot.ErrEmptyResult) {
+					log("Warning: events query returned no data")
+					err = nil
+				}
+				if err != nil {
+					return fmt.Errorf("failed to get events: %w", err)
+				}
+				eventLines := promplot.EventLines(events, req.timeFormat, location)
+				debug("Found %d events", len(eventLines))
+				vLines = append(vLines, eventLines...)
+			}
+			if *exemplars && err == nil {
+				for _, q := range req.queries {
+					log("Querying exemplars %q", q)
+					e, err := promplot.Exemplars(ctx, *promURL, q, req.time, req.queryRange, cfg)
+					if errors.Is(err, promplot.ErrExemplarsUnsupported) {
+						log("Warning: %v, plotting without exemplars", err)
+						break
+					}
+					if err != nil {
+						return fmt.Errorf("failed to get exemplars: %w", err)
+					}
+					exemplarPoints = append(exemplarPoints, e...)
+				}
+				debug("Found %d exemplars", len(exemplarPoints))
+			}
+		}
+		if errors.Is(err, promplot.ErrEmptyResult) {
+			if !*allowEmpty {
+				return promplot.ErrEmptyResult
+			}
+			log("Warning: query returned no data")
+			err = nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get metrics: %w", err)
+		}
+		points := 0
+		for _, sample := range metrics {
+			points += len(sample.Values)
+		}
+		debug("Fetched %d series with %d data points in %v", len(metrics), points, time.Since(start).Round(time.Millisecond))
+
+		if *dump != "" && !*dryRun {
+			log("Saving metrics to '%s'", *dump)
+			var buf bytes.Buffer
+			if err := promplot.DumpMatrix(metrics, &buf); err != nil {
+				return fmt.Errorf("failed to encode metrics: %w", err)
+			}
+			if err := writeFile(*dump, &buf, false); err != nil {
+				return fmt.Errorf("failed to save metrics: %w", err)
+			}
+		}
+
+		if *topN > 0 {
+			by := *sortBy
+			if by == "" {
+				by = "max"
+			}
+			log("Keeping top %d series by %s", *topN, by)
+			metrics, err = promplot.TopN(metrics, *topN, by, *topNOther)
+			if err != nil {
+				return fmt.Errorf("failed to select top series: %w", err)
+			}
+		}
+
+		if *aggregateBy != "" {
+			log("Aggregating %d series by %s", len(metrics), *aggregateBy)
+			metrics, err = promplot.Aggregate(metrics, *aggregateBy)
+			if err != nil {
+				return fmt.Errorf("failed to aggregate series: %w", err)
+			}
+		}
+
+		if *maxPoints > 0 {
+			log("Downsampling to %d points per series", *maxPoints)
+			metrics = promplot.Downsample(metrics, *maxPoints)
 		}
-		_, err = plot.WriteTo(out)
-		fatal(err, "failed to copy to file")
+
+		if *dryRun {
+			log("Fetched %d series with %d data points:", len(metrics), points)
+			for _, sample := range metrics {
+				log("  %s", sample.Metric)
+			}
+			if *dump != "" {
+				log("Would save metrics to '%s'", *dump)
+			}
+			switch {
+			case *file == "-":
+				log("Would write to stdout")
+			case *file != "":
+				log("Would write to '%s'", *file)
+			case *s3Bucket != "":
+				log("Would upload to s3://%s/%s", *s3Bucket, req.s3Key)
+			case *slackToken != "":
+				log("Would upload to Slack channel %s", strings.Join(promplot.SlackChannels(*channel), ", "))
+			case *telegramToken != "":
+				log("Would send to Telegram chat %q", *telegramChat)
+			case *discordHook != "":
+				log("Would post to Discord webhook")
+			case *webhookURL != "":
+				log("Would post to webhook")
+			case *smtpHost != "":
+				log("Would send email to %s", *smtpTo)
+			case *clipboard:
+				log("Would copy to clipboard")
+			}
+			return nil
+		}
+
+		// Plot
+		opts := promplot.PlotOptions{
+			Title:          req.title,
+			Subtitle:       req.subtitle,
+			Palette:        *palette,
+			Theme:          *theme,
+			PaletteSize:    *paletteSize,
+			Width:          *width,
+			Height:         *height,
+			Margin:         *margin,
+			Background:     *background,
+			Font:           *font,
+			EmbedFonts:     *embedFonts,
+			TitleFontSize:  *titleSize,
+			TitleAlign:     *titleAlign,
+			TitlePadding:   *titlePad,
+			HideTitle:      *noTitle,
+			TickFontSize:   *tickSize,
+			Legend:         *legend,
+			LegendFormat:   *legendFmt,
+			LegendMax:      *legendMax,
+			KeepLabels:     splitList(*keepLabels),
+			DropLabels:     splitList(*dropLabels),
+			MergeSeries:    *merge,
+			YLabel:         *yLabel,
+			YUnit:          *yUnit,
+			SortBy:         *sortBy,
+			XMin:           *xMin,
+			XMax:           *xMax,
+			YMin:           yMin,
+			YMax:           yMax,
+			LogY:           *logY,
+			Grid:           *grid,
+			TimeFormat:     req.timeFormat,
+			Location:       location,
+			Freshness:      *freshness,
+			SmoothPoints:   smoothPoints,
+			SmoothWindow:   smoothWindow,
+			SmoothOverlay:  *smoothRaw,
+			Style:          *style,
+			PNGCompression: *pngLevel,
+			JPEGQuality:    *jpegQuality,
+			GapThreshold:   gapThreshold,
+			LineWidth:      *lineWidth,
+			Dash:           *dash,
+			FillUnder:      *fillUnder,
+			Heatmap:        *heatmap,
+			HLines:         hLines,
+			VLines:         vLines,
+			Exemplars:      exemplarPoints,
+		}
+		// Each format is plotted separately, warnings are only logged once per run
+		warned := map[string]bool{}
+		opts.Logf = func(format string, a ...interface{}) {
+			if msg := fmt.Sprintf(format, a...); !warned[msg] {
+				warned[msg] = true
+				log("Warning: %s", msg)
+			}
+		}
+		render := func(format string) (io.WriterTo, error) {
+			// Plotting cannot be interrupted, so it is not started after the deadline.
+			if err := ctx.Err(); err != nil {
+				return nil, fmt.Errorf("stopped before creating %s: %w", format, err)
+			}
+			if format == "csv" {
+				log("Creating CSV")
+				var buf bytes.Buffer
+				if err := promplot.CSV(metrics, &buf); err != nil {
+					return nil, fmt.Errorf("failed to create csv: %w", err)
+				}
+				return &buf, nil
+			}
+			log("Creating %s plot %q", format, req.title)
+			start := time.Now()
+			opts.Format = format
+			plot, err := promplot.PlotWithOptions(metrics, opts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create plot: %w", err)
+			}
+			debug("Created plot in %v", time.Since(start).Round(time.Millisecond))
+			if err := ctx.Err(); err != nil {
+				return nil, fmt.Errorf("stopped after creating %s: %w", format, err)
+			}
+			return plot, nil
+		}
+		// Files are replaced atomically when watching so readers never see partial images
+		save := writeFile
+		if *watch > 0 {
+			save = replaceFile
+		}
+
+		switch {
+		// Respond to HTTP request
+		case w != nil:
+			plot, err := render(req.format)
+			if err != nil {
+				return err
+			}
+			if _, err := plot.WriteTo(w); err != nil {
+				return fmt.Errorf("failed to write plot: %w", err)
+			}
+
+		// Write to multiple files, format is inferred from each extension
+		case len(files) > 1:
+			for _, f := range files {
+				plot, err := render(fileFormat(f))
+				if err != nil {
+					return err
+				}
+				log("Writing to '%s'", f)
+				if err := save(f, plot, *gzipOut || gzipFile(f)); err != nil {
+					return fmt.Errorf("failed to write file: %w", err)
+				}
+			}
+
+		// Write to file
+		case *file != "":
+			plot, err := render(req.format)
+			if err != nil {
+				return err
+			}
+			if *file == "-" {
+				log("Writing to stdout")
+				debug("Content-Type: %s", promplot.ContentType(req.format))
+				if *gzipOut {
+					debug("Content-Encoding: gzip")
+				}
+				if err := writeTo(os.Stdout, plot, *gzipOut); err != nil {
+					return fmt.Errorf("failed to write to stdout: %w", err)
+				}
+			} else {
+				log("Writing to '%s'", *file)
+				if err := save(*file, plot, *gzipOut || gzipFile(*file)); err != nil {
+					return fmt.Errorf("failed to write file: %w", err)
+				}
+			}
+
+		// Upload to S3
+		case *s3Bucket != "":
+			plot, err := render(req.format)
+			if err != nil {
+				return err
+			}
+			log("Uploading to s3://%s/%s", *s3Bucket, req.s3Key)
+			start = time.Now()
+			if err := promplot.S3Context(ctx, *s3Bucket, req.s3Key, *s3Region, plot); err != nil {
+				return fmt.Errorf("failed to upload to S3: %w", err)
+			}
+			debug("Uploaded in %v", time.Since(start).Round(time.Millisecond))
 
 		// Upload to Slack
-	} else {
-		log("Uploading to Slack channel %q", *channel)
-		fatal(promplot.Slack(*slackToken, *channel, *title, plot), "failed to upload to Slack")
+		case *slackToken != "":
+			plot, err := render(req.format)
+			if err != nil {
+				return err
+			}
+			log("Uploading to Slack channel %s", strings.Join(promplot.SlackChannels(*channel), ", "))
+			start = time.Now()
+			if err := promplot.SlackContext(ctx, *slackToken, *channel, req.title, plot, promplot.SlackConfig{
+				ThreadTimestamp: *threadTS,
+				Message:         *slackMsg,
+				Filename:        "promplot." + req.format,
+				Retries:         *retries,
+				Proxy:           proxyURL,
+			}); err != nil {
+				return fmt.Errorf("failed to upload to Slack: %w", err)
+			}
+			debug("Uploaded in %v", time.Since(start).Round(time.Millisecond))
+
+		// Send to Telegram
+		case *telegramToken != "":
+			plot, err := render(req.format)
+			if err != nil {
+				return err
+			}
+			log("Sending to Telegram chat %q", *telegramChat)
+			start = time.Now()
+			if err := promplot.TelegramContext(ctx, *telegramToken, *telegramChat, req.title, plot); err != nil {
+				return fmt.Errorf("failed to send to Telegram: %w", err)
+			}
+			debug("Sent in %v", time.Since(start).Round(time.Millisecond))
+
+		// Post to Discord
+		case *discordHook != "":
+			plot, err := render(req.format)
+			if err != nil {
+				return err
+			}
+			log("Posting to Discord webhook")
+			start = time.Now()
+			if err := promplot.DiscordContext(ctx, *discordHook, req.title, plot); err != nil {
+				return fmt.Errorf("failed to post to Discord: %w", err)
+			}
+			debug("Posted in %v", time.Since(start).Round(time.Millisecond))
+
+		// Post to webhook
+		case *webhookURL != "":
+			plot, err := render(req.format)
+			if err != nil {
+				return err
+			}
+			log("Posting to webhook")
+			start = time.Now()
+			if err := promplot.WebhookContext(ctx, *webhookURL, req.title, plot, promplot.WebhookConfig{
+				Headers:     hookHeaders,
+				ContentType: promplot.ContentType(req.format),
+				Multipart:   *webhookMultipart,
+				Filename:    "promplot." + req.format,
+			}); err != nil {
+				return fmt.Errorf("failed to post to webhook: %w", err)
+			}
+			debug("Posted in %v", time.Since(start).Round(time.Millisecond))
+
+		// Send email
+		case *smtpHost != "":
+			plot, err := render(req.format)
+			if err != nil {
+				return err
+			}
+			var to []string
+			for _, addr := range strings.Split(*smtpTo, ",") {
+				to = append(to, strings.TrimSpace(addr))
+			}
+			log("Sending email to %s", strings.Join(to, ", "))
+			start = time.Now()
+			if err := promplot.EmailContext(ctx, promplot.SMTPConfig{
+				Host:     *smtpHost,
+				Port:     *smtpPort,
+				Username: *smtpUser,
+				Password: *smtpPass,
+				From:     *smtpFrom,
+				To:       to,
+				Filename: "promplot." + req.format,
+			}, req.title, plot); err != nil {
+				return fmt.Errorf("failed to send email: %w", err)
+			}
+			debug("Sent in %v", time.Since(start).Round(time.Millisecond))
+
+		// Copy to clipboard
+		case *clipboard:
+			plot, err := render(req.format)
+			if err != nil {
+				return err
+			}
+			log("Copying to clipboard")
+			start = time.Now()
+			if err := promplot.Clipboard(plot, req.format); err != nil {
+				return fmt.Errorf("failed to copy to clipboard: %w", err)
+			}
+			debug("Copied in %v", time.Since(start).Round(time.Millisecond))
+		}
+
+		log("Done")
+		return nil
+	}
+
+	if *serve != "" {
+		// Plots are created one at a time to limit the load on Prometheus.
+		// The parameters query, range and format of a request override the flags.
+		var mu sync.Mutex
+		mux := http.NewServeMux()
+		mux.HandleFunc("/plot", func(w http.ResponseWriter, r *http.Request) {
+			params := r.URL.Query()
+			queries, queryRange, format := *queries, *queryRange, *format
+			if q := params["query"]; len(q) > 0 {
+				queries = q
+			}
+			if s := params.Get("range"); s != "" {
+				d, err := model.ParseDuration(s)
+				if err != nil || d <= 0 {
+					http.Error(w, fmt.Sprintf("invalid range %q", s), http.StatusBadRequest)
+					return
+				}
+				queryRange = time.Duration(d)
+			}
+			if f := params.Get("format"); f != "" {
+				format = f
+			}
+			req, errs := newRequest(time.Now(), queries, queryRange, format)
+			if len(errs) > 0 {
+				http.Error(w, strings.Join(errs, "\n"), http.StatusBadRequest)
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			// Buffer the plot so that errors can still be reported with a proper status
+			var buf bytes.Buffer
+			if err := run(r.Context(), req, &buf); err != nil {
+				log("Error: %v", err)
+				status := http.StatusInternalServerError
+				if errors.Is(err, promplot.ErrQuery) || errors.Is(err, promplot.ErrEmptyResult) {
+					status = http.StatusBadGateway
+				}
+				// Errors can contain details of the Prometheus server, they are only logged
+				http.Error(w, http.StatusText(status), status)
+				return
+			}
+			w.Header().Set("Content-Type", promplot.ContentType(req.format))
+			w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+			w.Write(buf.Bytes())
+		})
+		srv := &http.Server{
+			Addr:    *serve,
+			Handler: http.TimeoutHandler(mux, *serveTimeout, "timeout while creating plot\n"),
+		}
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			s := <-stop
+			log("Stopping server after %v", s)
+			ctx, cancel := context.WithTimeout(context.Background(), *serveTimeout)
+			defer cancel()
+			srv.Shutdown(ctx)
+		}()
+		log("Serving plots on %s/plot", *serve)
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			fatal(err, "failed to serve plots")
+		}
+		return
 	}
 
-	log("Done")
+	if *watch == 0 {
+		if err := run(ctx, req, nil); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitCode(err))
+		}
+		return
+	}
+
+	// Render again after every interval until interrupted
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(*watch)
+	defer ticker.Stop()
+	for {
+		// Failed runs are retried in the next interval
+		req, _ := newRequest(time.Now(), *queries, *queryRange, *format)
+		ctx, cancel := newContext()
+		if err := run(ctx, req, nil); err != nil {
+			log("Error: %v", err)
+		}
+		cancel()
+		select {
+		case <-ticker.C:
+		case s := <-stop:
+			log("Stopping after %v", s)
+			return
+		}
+	}
+}
+
+// plotRequest holds the settings of a single plot which can change while running,
+// e.g. the time with -watch or overrides in requests to the HTTP server.
+// Placeholders in queries and title are already expanded.
+type plotRequest struct {
+	time        time.Time
+	queries     []string
+	eventsQuery string
+	queryRange  time.Duration
+	step        time.Duration
+	title       string
+	subtitle    string
+	s3Key       string
+	format      string
+	timeFormat  string
+}
+
+// remoteReadQueries fetches all queries via remote read and merges the results like promplot.MetricsQueries.
+func remoteReadQueries(ctx context.Context, endpoint string, queries []string, queryTime time.Time, duration time.Duration, cfg promplot.MetricsConfig) (model.Matrix, error) {
+	metrics := model.Matrix{}
+	for _, query := range queries {
+		m, err := promplot.MetricsRemoteRead(ctx, endpoint, query, queryTime, duration, step, cfg)
+		if err != nil && !errors.Is(err, promplot.ErrEmptyResult) {
+			return nil, fmt.Errorf("query %q: %w", query, err)
+		}
+		metrics = append(metrics, m...)
+	}
+	return metrics, promplot.CheckEmpty(metrics)
+}
+
+// Number of characters of queries fitting into the title in the default size
+const maxQueryTitle = 40
+
+// shorten cuts s off after max characters and marks it with "...".
+func shorten(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max-3]) + "..."
+}
+
+// isSet reports whether a flag was set on the command line or in the config file.
+func isSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// registerFont registers the TrueType font at path under name.
+func registerFont(name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return promplot.RegisterFont(name, f)
+}
+
+// loadConfig sets all flags from a config file which are not yet set on the command line.
+// Keys are flag names; lists can be used for flags that can be repeated.
+func loadConfig(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// YAML is a superset of JSON so both can be parsed the same way
+	var values map[string]interface{}
+	if err = yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	for name, v := range values {
+		if flag.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("unknown key in %s: %s", path, name)
+		}
+		if set[name] {
+			continue
+		}
+		list, ok := v.([]interface{})
+		if !ok {
+			list = []interface{}{v}
+		}
+		for _, item := range list {
+			if err = flag.Set(name, fmt.Sprint(item)); err != nil {
+				return fmt.Errorf("invalid value for %s in %s: %v", name, path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// fileFormat returns the format matching the extension of a file.
+// Compressed .svgz files are SVG.
+func fileFormat(path string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if ext == "svgz" {
+		return "svg"
+	}
+	return ext
+}
+
+// gzipFile reports whether a file should be compressed based on its extension.
+func gzipFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".svgz")
+}
+
+// writeFile creates a file and writes the plot to it, optionally gzip compressed.
+func writeFile(path string, plot io.WriterTo, compress bool) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = writeTo(f, plot, compress); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
 
+// checkWritable reports whether a file can be created in the directory of path without changing path itself.
+func checkWritable(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// replaceFile writes the plot to a temporary file and renames it to path,
+// so that the file at path is replaced at once.
+func replaceFile(path string, plot io.WriterTo, compress bool) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	if err = writeTo(f, plot, compress); err == nil {
+		err = f.Chmod(0644)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// writeTo writes the plot to w, optionally gzip compressed.
+func writeTo(w io.Writer, plot io.WriterTo, compress bool) error {
+	if !compress {
+		_, err := plot.WriteTo(w)
+		return err
+	}
+	gz := gzip.NewWriter(w)
+	if _, err := plot.WriteTo(gz); err != nil {
+		gz.Close()
+		return err
+	}
+	// Closing flushes the remaining data and writes the footer
+	return gz.Close()
+}
+
+// roundTimeRange extends the time range ending at end to round times in loc, e.g. from 00:00 to 00:00 for a range of a week.
+// The granularity depends on the length of the range: 5 minutes up to 2 hours, hours up to 2 days and days beyond.
+// It returns the new end and length of the range.
+func roundTimeRange(end time.Time, queryRange time.Duration, loc *time.Location) (time.Time, time.Duration) {
+	granularity := 24 * time.Hour
+	switch {
+	case queryRange <= 2*time.Hour:
+		granularity = 5 * time.Minute
+	case queryRange <= 48*time.Hour:
+		granularity = time.Hour
+	}
+	start := floorTime(end.Add(-queryRange), granularity, loc)
+	if floored := floorTime(end, granularity, loc); !floored.Equal(end) {
+		end = nextTime(floored, granularity)
+	}
+	return end, end.Sub(start)
+}
+
+// floorTime rounds t down to a multiple of granularity since midnight in loc.
+func floorTime(t time.Time, granularity time.Duration, loc *time.Location) time.Time {
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	return midnight.Add(t.Sub(midnight).Truncate(granularity))
+}
+
+// nextTime returns the next round time after t which is already rounded by floorTime.
+func nextTime(t time.Time, granularity time.Duration) time.Time {
+	if granularity == 24*time.Hour {
+		// Days are shorter or longer when daylight saving time changes
+		return t.AddDate(0, 0, 1)
+	}
+	return t.Add(granularity)
+}
+
+// splitList splits a comma-separated flag value and removes empty entries.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// parseHeaders parses values of a header flag in the format Name:Value.
+// It returns a message for each invalid value.
+func parseHeaders(name string, values []string) (http.Header, []string) {
+	headers := http.Header{}
+	var errs []string
+	for _, h := range values {
+		i := strings.Index(h, ":")
+		if i <= 0 {
+			errs = append(errs, fmt.Sprintf("invalid %s %q: must be in the format Name:Value", name, h))
+			continue
+		}
+		headers.Add(strings.TrimSpace(h[:i]), strings.TrimSpace(h[i+1:]))
+	}
+	return headers, errs
+}
+
+var (
+	// Counters are named with a _total suffix by convention
+	counterName = regexp.MustCompile(`[a-zA-Z_:][a-zA-Z0-9_:]*_total\b`)
+	rateCall    = regexp.MustCompile(`\b(rate|irate|increase)\s*\(`)
+	quoted      = regexp.MustCompile(`"(\\.|[^"\\])*"|'(\\.|[^'\\])*'|` + "`[^`]*`")
+)
+
+// rawCounter returns a counter used in query if the query does not calculate a rate or increase.
+// This is only a heuristic to warn about plots which are rarely useful.
+func rawCounter(query string) string {
+	// Label values like job="jobs_total" are not metrics
+	query = quoted.ReplaceAllString(query, `""`)
+	if rateCall.MatchString(query) {
+		return ""
+	}
+	return counterName.FindString(query)
+}
+
+// sameFormat reports whether a file extension matches a format.
+func sameFormat(ext, format string) bool {
+	aliases := map[string]string{"jpeg": "jpg", "tiff": "tif"}
+	normalize := func(f string) string {
+		f = strings.ToLower(f)
+		if a, ok := aliases[f]; ok {
+			return a
+		}
+		return f
+	}
+	return normalize(ext) == normalize(format)
+}
+
+// readQuery loads a single query from a file and removes trailing newlines.
+func readQuery(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	query := strings.TrimRight(string(data), "\r\n")
+	if strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("'%s' contains no query", path)
+	}
+	return query, nil
+}
+
+// readMatrix loads metrics from a JSON file.
+func readMatrix(path string) (model.Matrix, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return promplot.LoadMatrix(f)
+}
+
+// logLevel controls which messages are written.
+type logLevel int
+
+const (
+	levelOff logLevel = iota
+	levelNormal
+	levelVerbose
+)
+
+// logger writes messages up to its level.
+type logger struct {
+	level logLevel
+	w     io.Writer
+}
+
+// log writes normal progress messages.
+func (l logger) log(format string, a ...interface{}) {
+	l.write(levelNormal, format, a...)
+}
+
+// debug writes messages only shown in verbose mode.
+func (l logger) debug(format string, a ...interface{}) {
+	l.write(levelVerbose, format, a...)
+}
+
+func (l logger) write(level logLevel, format string, a ...interface{}) {
+	if l.level >= level {
+		fmt.Fprintf(l.w, format+"\n", a...)
+	}
+}
+
+// Output and exit of fatal, replaced in tests.
+var (
+	stderr io.Writer = os.Stderr
+	exit             = os.Exit
+)
+
+// fatal prints msg with a non-nil err and exits with the code of its failure cause.
 func fatal(err error, msg string) {
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "msg: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(stderr, "%s: %v\n", msg, err)
+		exit(exitCode(err))
+	}
+}
+
+// exitCode returns the exit code for the failure cause of err.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, promplot.ErrEmptyResult):
+		return exitEmpty
+	case errors.Is(err, promplot.ErrQuery):
+		return exitQuery
+	case errors.Is(err, promplot.ErrPlot):
+		return exitPlot
+	case errors.Is(err, promplot.ErrUpload):
+		return exitUpload
 	}
+	return exitError
 }
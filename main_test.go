@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"qvl.io/promplot/promplot"
+)
+
+func TestFatal(t *testing.T) {
+	defer func(w io.Writer, e func(int)) { stderr, exit = w, e }(stderr, exit)
+
+	tests := []struct {
+		err    error
+		output string
+		code   int
+	}{
+		{},
+		{err: errors.New("connection refused"), output: "failed to get metrics: connection refused\n", code: exitError},
+		{err: fmt.Errorf("timeout: %w", promplot.ErrQuery), output: "failed to get metrics: timeout: query failed\n", code: exitQuery},
+		{err: promplot.ErrEmptyResult, output: "failed to get metrics: query returned no data\n", code: exitEmpty},
+		{err: fmt.Errorf("bad font: %w", promplot.ErrPlot), output: "failed to get metrics: bad font: plot failed\n", code: exitPlot},
+		{err: fmt.Errorf("denied: %w", promplot.ErrUpload), output: "failed to get metrics: denied: upload failed\n", code: exitUpload},
+	}
+
+	for i, tt := range tests {
+		var buf bytes.Buffer
+		code := 0
+		stderr = &buf
+		exit = func(c int) { code = c }
+		fatal(tt.err, "failed to get metrics")
+		if buf.String() != tt.output {
+			t.Errorf("%d. expected output %q, got %q", i, tt.output, buf.String())
+		}
+		if code != tt.code {
+			t.Errorf("%d. expected exit code %d, got %d", i, tt.code, code)
+		}
+	}
+}
+
+func TestShorten(t *testing.T) {
+	tests := []struct {
+		text      string
+		shortened string
+	}{
+		{text: "up", shortened: "up"},
+		{text: "0123456789", shortened: "0123456789"},
+		{text: "0123456789a", shortened: "0123456..."},
+		{text: "ääääääääääää", shortened: "äääääää..."},
+	}
+
+	for _, tt := range tests {
+		if s := shorten(tt.text, 10); s != tt.shortened {
+			t.Errorf("shortening %q: expected %q, got %q", tt.text, tt.shortened, s)
+		}
+	}
+}
+
+func TestReadQuery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "promplot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		content string
+		query   string
+		invalid bool
+	}{
+		{content: "up", query: "up"},
+		{content: "up\n", query: "up"},
+		{content: "sum by (job) (\n  rate(http_requests_total[$__interval])\n)\r\n\n", query: "sum by (job) (\n  rate(http_requests_total[$__interval])\n)"},
+		{content: "\n \n", invalid: true},
+		{content: "", invalid: true},
+	}
+
+	for i, tt := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("%d.promql", i))
+		if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		query, err := readQuery(path)
+		if err != nil && !tt.invalid {
+			t.Errorf("%d. reading failed unexpectedly: %v", i, err)
+		}
+		if err == nil && tt.invalid {
+			t.Errorf("%d. reading should have failed", i)
+		}
+		if query != tt.query {
+			t.Errorf("%d. expected query %q, got %q", i, tt.query, query)
+		}
+	}
+
+	if _, err := readQuery(filepath.Join(dir, "missing.promql")); err == nil {
+		t.Errorf("reading a missing file should fail")
+	}
+}
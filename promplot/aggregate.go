@@ -0,0 +1,81 @@
+package promplot
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/common/model"
+)
+
+// SeriesAggregates are the aggregates Aggregate can combine series with.
+var SeriesAggregates = []string{"sum", "avg", "max", "min"}
+
+// Aggregate combines all series into a single series like the aggregation operators of PromQL.
+// The aggregate is one of SeriesAggregates.
+// Values are combined at matching timestamps. Series without a value at a timestamp are skipped instead of counting as zero,
+// so avg only divides by the number of series with a value. NaN values are skipped the same way.
+// The resulting series has a label named like the aggregate with the number of combined series, e.g. sum="3 series".
+func Aggregate(metrics model.Matrix, by string) (model.Matrix, error) {
+	if err := validSeriesAggregate(by); err != nil {
+		return nil, err
+	}
+	if len(metrics) == 0 {
+		return model.Matrix{}, nil
+	}
+	metric := model.Metric{model.LabelName(by): model.LabelValue(fmt.Sprintf("%d series", len(metrics)))}
+	return model.Matrix{combineSeries(metrics, metric, by)}, nil
+}
+
+func validSeriesAggregate(by string) error {
+	for _, a := range SeriesAggregates {
+		if by == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown aggregate %q, valid aggregates are: %s", by, strings.Join(SeriesAggregates, ", "))
+}
+
+// combineSeries aggregates the values of all series at each timestamp. NaN values are ignored.
+func combineSeries(metrics model.Matrix, metric model.Metric, by string) *model.SampleStream {
+	type combined struct {
+		sum, min, max float64
+		n             int
+	}
+	values := map[model.Time]*combined{}
+	for _, sample := range metrics {
+		for _, v := range sample.Values {
+			f := float64(v.Value)
+			if math.IsNaN(f) {
+				continue
+			}
+			c, ok := values[v.Timestamp]
+			if !ok {
+				values[v.Timestamp] = &combined{sum: f, min: f, max: f, n: 1}
+				continue
+			}
+			c.sum += f
+			c.min = math.Min(c.min, f)
+			c.max = math.Max(c.max, f)
+			c.n++
+		}
+	}
+	result := &model.SampleStream{Metric: metric, Values: make([]model.SamplePair, 0, len(values))}
+	for t, c := range values {
+		v := c.sum
+		switch by {
+		case "avg":
+			v = c.sum / float64(c.n)
+		case "max":
+			v = c.max
+		case "min":
+			v = c.min
+		}
+		result.Values = append(result.Values, model.SamplePair{Timestamp: t, Value: model.SampleValue(v)})
+	}
+	sort.Slice(result.Values, func(i, j int) bool {
+		return result.Values[i].Timestamp < result.Values[j].Timestamp
+	})
+	return result
+}
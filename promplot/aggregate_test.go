@@ -0,0 +1,51 @@
+package promplot
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestAggregate(t *testing.T) {
+	metrics := model.Matrix{
+		{Metric: model.Metric{"job": "a"}, Values: []model.SamplePair{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 4}}},
+		{Metric: model.Metric{"job": "b"}, Values: []model.SamplePair{{Timestamp: 1000, Value: 3}, {Timestamp: 3000, Value: 5}}},
+		{Metric: model.Metric{"job": "c"}, Values: []model.SamplePair{{Timestamp: 1000, Value: 8}, {Timestamp: 2000, Value: model.SampleValue(math.NaN())}}},
+	}
+	tests := []struct {
+		by     string
+		values []model.SampleValue
+	}{
+		// Missing and NaN values are skipped
+		{by: "sum", values: []model.SampleValue{12, 4, 5}},
+		{by: "avg", values: []model.SampleValue{4, 4, 5}},
+		{by: "max", values: []model.SampleValue{8, 4, 5}},
+		{by: "min", values: []model.SampleValue{1, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		aggregated, err := Aggregate(metrics, tt.by)
+		if err != nil {
+			t.Fatalf("%s: aggregating failed unexpectedly: %v", tt.by, err)
+		}
+		if len(aggregated) != 1 {
+			t.Fatalf("%s: expected a single series, got %d", tt.by, len(aggregated))
+		}
+		if m := (model.Metric{model.LabelName(tt.by): "3 series"}); !aggregated[0].Metric.Equal(m) {
+			t.Errorf("%s: expected labels %v, got %v", tt.by, m, aggregated[0].Metric)
+		}
+		expected := []model.SamplePair{{Timestamp: 1000, Value: tt.values[0]}, {Timestamp: 2000, Value: tt.values[1]}, {Timestamp: 3000, Value: tt.values[2]}}
+		if !reflect.DeepEqual(aggregated[0].Values, expected) {
+			t.Errorf("%s: expected %v, got %v", tt.by, expected, aggregated[0].Values)
+		}
+	}
+
+	if aggregated, err := Aggregate(nil, "sum"); err != nil || len(aggregated) != 0 {
+		t.Errorf("expected no series for empty input, got %v, %v", aggregated, err)
+	}
+	if _, err := Aggregate(metrics, "last"); err == nil {
+		t.Error("unknown aggregate should fail")
+	}
+}
@@ -0,0 +1,102 @@
+package promplot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// Cache stores fetched metrics as JSON files on disk
+// to avoid running the same expensive queries repeatedly.
+type Cache struct {
+	// Dir contains one file per entry. It is created when the first entry is stored.
+	Dir string
+	// TTL is the maximum age of entries. Zero means entries never expire.
+	TTL time.Duration
+}
+
+// CacheKey creates a key for Cache from everything that affects the result of a query,
+// e.g. its URL, query, time, range and step.
+func CacheKey(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		// Separate parts so that ("ab", "c") and ("a", "bc") differ
+		fmt.Fprintf(h, "%d:%s", len(p), p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the metrics stored under key.
+// It reports false if there is no entry, it expired or cannot be read.
+func (c Cache) Get(key string) (model.Matrix, bool) {
+	path := c.path(key)
+	info, err := os.Stat(path)
+	if err != nil || c.expired(info) {
+		return nil, false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	m, err := LoadMatrix(f)
+	if err != nil {
+		return nil, false
+	}
+	return m, true
+}
+
+// Put stores metrics under key, replacing any existing entry.
+// Expired entries of other keys are removed.
+func (c Cache) Put(key string, metrics model.Matrix) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := DumpMatrix(metrics, &buf); err != nil {
+		return err
+	}
+	// Write to a temporary file first so readers never see partial entries
+	tmp, err := ioutil.TempFile(c.Dir, key+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	_, err = tmp.Write(buf.Bytes())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	c.prune()
+	return nil
+}
+
+// prune removes all expired entries. Errors are ignored since they only leave stale files behind.
+func (c Cache) prune() {
+	paths, _ := filepath.Glob(filepath.Join(c.Dir, "*.json"))
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil && c.expired(info) {
+			os.Remove(p)
+		}
+	}
+}
+
+func (c Cache) expired(info os.FileInfo) bool {
+	return c.TTL > 0 && time.Since(info.ModTime()) > c.TTL
+}
+
+func (c Cache) path(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
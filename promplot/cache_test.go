@@ -0,0 +1,68 @@
+package promplot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCacheKey(t *testing.T) {
+	if CacheKey("a", "b") != CacheKey("a", "b") {
+		t.Error("same parts should result in the same key")
+	}
+	if CacheKey("ab", "c") == CacheKey("a", "bc") {
+		t.Error("different parts should result in different keys")
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	c := Cache{Dir: dir, TTL: time.Minute}
+	key, other := CacheKey("up"), CacheKey("down")
+
+	if _, ok := c.Get(key); ok {
+		t.Fatal("empty cache should not contain entries")
+	}
+	metrics := testMatrix(2)
+	if err := c.Put(key, metrics); err != nil {
+		t.Fatalf("storing entry failed unexpectedly: %v", err)
+	}
+	cached, ok := c.Get(key)
+	if !ok {
+		t.Fatal("stored entry not found")
+	}
+	if !reflect.DeepEqual(cached, metrics) {
+		t.Errorf(`
+Expected: %v
+Got       %v`, metrics, cached)
+	}
+	if _, ok := c.Get(other); ok {
+		t.Error("entry of other key should not be found")
+	}
+
+	// Expire the entry
+	old := time.Now().Add(-2 * time.Minute)
+	if err := os.Chtimes(c.path(key), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(key); ok {
+		t.Error("expired entry should not be found")
+	}
+	if _, ok := (Cache{Dir: dir}).Get(key); !ok {
+		t.Error("entries should never expire without TTL")
+	}
+
+	// Storing another entry removes expired ones
+	if err := c.Put(other, metrics); err != nil {
+		t.Fatalf("storing entry failed unexpectedly: %v", err)
+	}
+	if _, err := os.Stat(c.path(key)); !os.IsNotExist(err) {
+		t.Errorf("expired entry should have been removed: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 {
+		t.Errorf("expected a single file in cache, got: %v", files)
+	}
+}
@@ -0,0 +1,48 @@
+package promplot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// ErrClipboardUnsupported is returned by Clipboard on platforms without clipboard support.
+var ErrClipboardUnsupported = errors.New("clipboard not supported on this platform")
+
+// Clipboard copies a PNG plot as image to the system clipboard, e.g. for pasting it into a chat.
+// It uses the tools available on each platform: osascript on macOS, wl-copy or xclip on Linux and PowerShell on Windows.
+// Errors wrap ErrUpload.
+func Clipboard(plot io.WriterTo, format string) (err error) {
+	defer wrap(ErrUpload, &err)
+	if format != "png" {
+		return fmt.Errorf("clipboard only supports png plots, got %q", format)
+	}
+	var buf bytes.Buffer
+	if _, err := plot.WriteTo(&buf); err != nil {
+		return fmt.Errorf("failed to write plot to buffer: %w", err)
+	}
+	return copyImage(buf.Bytes())
+}
+
+// tempImage writes a PNG image to a temporary file for tools which cannot read it from stdin.
+// The returned function removes the file again.
+func tempImage(png []byte) (string, func(), error) {
+	f, err := ioutil.TempFile("", "promplot-*.png")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	remove := func() { os.Remove(f.Name()) }
+	if _, err := f.Write(png); err != nil {
+		f.Close()
+		remove()
+		return "", nil, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		remove()
+		return "", nil, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	return f.Name(), remove, nil
+}
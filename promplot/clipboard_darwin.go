@@ -0,0 +1,21 @@
+package promplot
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+func copyImage(png []byte) error {
+	path, remove, err := tempImage(png)
+	if err != nil {
+		return err
+	}
+	defer remove()
+	// pbcopy only supports text
+	script := fmt.Sprintf(`set the clipboard to (read (POSIX file %q) as «class PNGf»)`, path)
+	if out, err := exec.Command("osascript", "-e", script).CombinedOutput(); err != nil {
+		return fmt.Errorf("osascript failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package promplot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+func copyImage(png []byte) error {
+	name, args := "xclip", []string{"-selection", "clipboard", "-t", "image/png", "-i"}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		name, args = "wl-copy", []string{"--type", "image/png"}
+	} else if os.Getenv("DISPLAY") == "" {
+		return fmt.Errorf("%w: neither WAYLAND_DISPLAY nor DISPLAY is set", ErrClipboardUnsupported)
+	}
+	if _, err := exec.LookPath(name); err != nil {
+		return fmt.Errorf("%w: %s is not installed", ErrClipboardUnsupported, name)
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(png)
+	// Output is not captured since both tools keep running in the background to serve the clipboard
+	if err := cmd.Run(); err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			return fmt.Errorf("%s failed with exit code %d", name, exit.ExitCode())
+		}
+		return fmt.Errorf("failed to run %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package promplot
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClipboard(t *testing.T) {
+	dir := t.TempDir()
+	copied := filepath.Join(dir, "copied")
+	cat, err := exec.LookPath("cat")
+	if err != nil {
+		t.Skip("cat is not installed")
+	}
+	// Fake xclip which stores the clipboard content in a file
+	script := "#!/bin/sh\n" + cat + " > " + copied + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "xclip"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"PATH": dir, "DISPLAY": ":0", "WAYLAND_DISPLAY": ""} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Setenv(name, value)
+	}
+
+	tests := []struct {
+		format  string
+		display string
+		err     string
+	}{
+		{format: "png", display: ":0"},
+		{format: "svg", display: ":0", err: `only supports png plots, got "svg"`},
+		{format: "png", err: "clipboard not supported on this platform"},
+	}
+
+	for i, tt := range tests {
+		os.Remove(copied)
+		os.Setenv("DISPLAY", tt.display)
+		err := Clipboard(bytes.NewBufferString("plot"), tt.format)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%d. expected error containing %q, got: %v", i, tt.err, err)
+			}
+			if !errors.Is(err, ErrUpload) {
+				t.Errorf("%d. error should wrap ErrUpload: %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d. copy failed unexpectedly: %v", i, err)
+			continue
+		}
+		b, err := ioutil.ReadFile(copied)
+		if err != nil {
+			t.Errorf("%d. nothing copied: %v", i, err)
+		} else if string(b) != "plot" {
+			t.Errorf("%d. expected %q to be copied, got %q", i, "plot", b)
+		}
+	}
+
+	os.Setenv("PATH", "")
+	if err := Clipboard(bytes.NewBufferString("plot"), "png"); !errors.Is(err, ErrClipboardUnsupported) {
+		t.Errorf("missing xclip should be unsupported, got: %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !darwin && !linux && !windows
+// +build !darwin,!linux,!windows
+
+package promplot
+
+func copyImage(png []byte) error {
+	return ErrClipboardUnsupported
+}
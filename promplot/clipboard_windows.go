@@ -0,0 +1,24 @@
+package promplot
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+func copyImage(png []byte) error {
+	path, remove, err := tempImage(png)
+	if err != nil {
+		return err
+	}
+	defer remove()
+	// The image is disposed so that the file can be removed afterwards
+	script := `Add-Type -AssemblyName System.Windows.Forms, System.Drawing; ` +
+		`$img = [System.Drawing.Image]::FromFile('` + strings.ReplaceAll(path, "'", "''") + `'); ` +
+		`[System.Windows.Forms.Clipboard]::SetImage($img); $img.Dispose()`
+	out, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-STA", "-Command", script).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("powershell failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package promplot
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/prometheus/common/model"
+)
+
+// CSV writes metric data as CSV to w.
+// The first column contains the timestamp, followed by one column per series.
+// Timestamps of all series are merged; cells are left empty where a series has no sample.
+// Errors wrap ErrPlot.
+func CSV(metrics model.Matrix, w io.Writer) (err error) {
+	defer wrap(ErrPlot, &err)
+	header := []string{"timestamp"}
+	var timestamps []model.Time
+	seen := map[model.Time]bool{}
+	values := make([]map[model.Time]model.SampleValue, len(metrics))
+	for s, sample := range metrics {
+		header = append(header, sample.Metric.String())
+		values[s] = make(map[model.Time]model.SampleValue, len(sample.Values))
+		for _, v := range sample.Values {
+			values[s][v.Timestamp] = v.Value
+			if !seen[v.Timestamp] {
+				seen[v.Timestamp] = true
+				timestamps = append(timestamps, v.Timestamp)
+			}
+		}
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i].Before(timestamps[j]) })
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("failed to write csv header: %w", err)
+	}
+	for _, t := range timestamps {
+		row := []string{t.String()}
+		for s := range metrics {
+			if v, ok := values[s][t]; ok {
+				row = append(row, v.String())
+			} else {
+				row = append(row, "")
+			}
+		}
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("failed to write csv row: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package promplot
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestCSV(t *testing.T) {
+	metrics := model.Matrix{
+		{
+			Metric: model.Metric{"job": "a"},
+			Values: []model.SamplePair{{Timestamp: 1000, Value: 1}, {Timestamp: 3000, Value: 3}},
+		},
+		{
+			Metric: model.Metric{"job": "b"},
+			Values: []model.SamplePair{{Timestamp: 2000, Value: 2.5}, {Timestamp: 3000, Value: 4}},
+		},
+	}
+	expected := `timestamp,"{job=""a""}","{job=""b""}"
+1,1,
+2,,2.5
+3,3,4
+`
+
+	var buf bytes.Buffer
+	if err := CSV(metrics, &buf); err != nil {
+		t.Fatalf("writing csv failed unexpectedly: %v", err)
+	}
+	if buf.String() != expected {
+		t.Errorf(`
+Expected: %s
+Got       %s`, expected, buf.String())
+	}
+}
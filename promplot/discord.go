@@ -0,0 +1,128 @@
+package promplot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Maximum number of retries when Discord is rate limiting
+const discordRetries = 3
+
+// Discord posts a plot as file attachment to a Discord channel using a webhook.
+// The title is used as message content.
+// Rate limited requests are retried after the delay Discord asks for.
+// Errors wrap ErrUpload.
+func Discord(webhookURL, title string, plot io.WriterTo) error {
+	return DiscordContext(context.Background(), webhookURL, title, plot)
+}
+
+// DiscordContext posts a plot to a Discord channel like Discord.
+// The request is aborted when ctx is canceled or its deadline is exceeded, also while waiting to retry.
+func DiscordContext(ctx context.Context, webhookURL, title string, plot io.WriterTo) (err error) {
+	defer wrap(ErrUpload, &err)
+	// The request is sent again when rate limited
+	var buf bytes.Buffer
+	if _, err := plot.WriteTo(&buf); err != nil {
+		return fmt.Errorf("failed to write plot to buffer: %w", err)
+	}
+	filename := "promplot"
+	if ext := imageExtension(buf.Bytes()); ext != "" {
+		// Discord only shows a preview of files with an image extension
+		filename += "." + ext
+	}
+
+	for attempt := 0; ; attempt++ {
+		body, contentType, err := discordMessage(title, filename, buf.Bytes())
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, body)
+		if err != nil {
+			return fmt.Errorf("failed to create discord request: %v", redact(err, webhookURL))
+		}
+		req.Header.Set("Content-Type", contentType)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("sending message exceeded the deadline: %v", redact(err, webhookURL))
+			}
+			// Error contains the URL, don't leak the token of the webhook
+			return fmt.Errorf("failed to send message: %v", redact(err, webhookURL))
+		}
+		data, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read discord response: %w", err)
+		}
+		if res.StatusCode >= 200 && res.StatusCode < 300 {
+			return nil
+		}
+
+		var result struct {
+			Message    string  `json:"message"`
+			RetryAfter float64 `json:"retry_after"`
+		}
+		if err := json.Unmarshal(data, &result); err != nil || result.Message == "" {
+			return fmt.Errorf("discord api error (status %s): %s", res.Status, strings.TrimSpace(string(data)))
+		}
+		if res.StatusCode != http.StatusTooManyRequests || attempt >= discordRetries {
+			return fmt.Errorf("discord api error: %s", result.Message)
+		}
+		// retry_after is given in seconds
+		timer := time.NewTimer(time.Duration(result.RetryAfter * float64(time.Second)))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("discord api error: %s, stopped retrying: %v", result.Message, ctx.Err())
+		}
+	}
+}
+
+// discordMessage creates a multipart webhook request with a single file.
+func discordMessage(title, filename string, file []byte) (io.Reader, string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	payload, err := json.Marshal(struct {
+		Content string `json:"content"`
+	}{title})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode message: %w", err)
+	}
+	if err := mw.WriteField("payload_json", string(payload)); err != nil {
+		return nil, "", fmt.Errorf("failed to write message: %w", err)
+	}
+	w, err := mw.CreateFormFile("files[0]", filename)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create file field: %w", err)
+	}
+	if _, err := w.Write(file); err != nil {
+		return nil, "", fmt.Errorf("failed to write plot to request: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to close request body: %w", err)
+	}
+	return &body, mw.FormDataContentType(), nil
+}
+
+// imageExtension detects the file extension of common image formats from their content.
+// It returns an empty string for other formats.
+func imageExtension(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/png":
+		return "png"
+	case "image/jpeg":
+		return "jpg"
+	case "application/pdf":
+		return "pdf"
+	}
+	return ""
+}
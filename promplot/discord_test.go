@@ -0,0 +1,80 @@
+package promplot
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDiscord(t *testing.T) {
+	var requests int
+	var payload, filename, file string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/invalid" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Unknown Webhook", "code": 10015}`))
+			return
+		}
+		if requests == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"message": "You are being rate limited.", "retry_after": 0.01, "global": false}`))
+			return
+		}
+		payload = r.FormValue("payload_json")
+		f, header, err := r.FormFile("files[0]")
+		if err != nil {
+			t.Errorf("missing file: %v", err)
+			return
+		}
+		data, _ := ioutil.ReadAll(f)
+		filename, file = header.Filename, string(data)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	plot := "\x89PNG\r\n\x1a\nplot"
+	if err := Discord(srv.URL+"/webhook", "Load", bytes.NewBufferString(plot)); err != nil {
+		t.Fatalf("sending failed unexpectedly: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("rate limited request should have been retried, got %d requests", requests)
+	}
+	if payload != `{"content":"Load"}` {
+		t.Errorf("unexpected payload: %s", payload)
+	}
+	if filename != "promplot.png" || file != plot {
+		t.Errorf("unexpected file %s: %q", filename, file)
+	}
+
+	err := Discord(srv.URL+"/invalid", "Load", bytes.NewBufferString(plot))
+	if err == nil || !strings.Contains(err.Error(), "Unknown Webhook") {
+		t.Errorf("expected api error message, got: %v", err)
+	}
+	if !errors.Is(err, ErrUpload) {
+		t.Errorf("expected upload error, got: %v", err)
+	}
+}
+
+func TestDiscordRateLimit(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"message": "You are being rate limited.", "retry_after": 0.001}`))
+	}))
+	defer srv.Close()
+
+	err := Discord(srv.URL, "Load", bytes.NewBufferString("plot"))
+	if err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Errorf("expected rate limit error, got: %v", err)
+	}
+	if requests != discordRetries+1 {
+		t.Errorf("expected %d requests, got %d", discordRetries+1, requests)
+	}
+}
@@ -0,0 +1,73 @@
+package promplot
+
+import (
+	"math"
+
+	"github.com/prometheus/common/model"
+)
+
+// Downsample reduces each series to at most maxPoints samples
+// using the largest-triangle-three-buckets algorithm, which preserves the visual shape of the data.
+// Series with fewer samples are left untouched. Values of maxPoints below 3 are treated as 3.
+func Downsample(metrics model.Matrix, maxPoints int) model.Matrix {
+	if maxPoints < 3 {
+		maxPoints = 3
+	}
+	result := make(model.Matrix, len(metrics))
+	for i, sample := range metrics {
+		if len(sample.Values) <= maxPoints {
+			result[i] = sample
+			continue
+		}
+		result[i] = &model.SampleStream{
+			Metric: sample.Metric,
+			Values: lttb(sample.Values, maxPoints),
+		}
+	}
+	return result
+}
+
+// lttb implements largest-triangle-three-buckets downsampling.
+// See https://skemman.is/bitstream/1946/15343/3/SS_MSthesis.pdf
+func lttb(data []model.SamplePair, threshold int) []model.SamplePair {
+	sampled := make([]model.SamplePair, 0, threshold)
+	sampled = append(sampled, data[0])
+
+	// Points between first and last are split into buckets
+	every := float64(len(data)-2) / float64(threshold-2)
+	a := 0
+	for i := 0; i < threshold-2; i++ {
+		// Average of next bucket
+		avgStart := int(math.Floor(float64(i+1)*every)) + 1
+		avgEnd := int(math.Floor(float64(i+2)*every)) + 1
+		if avgEnd > len(data) {
+			avgEnd = len(data)
+		}
+		var avgX, avgY float64
+		for _, p := range data[avgStart:avgEnd] {
+			avgX += float64(p.Timestamp)
+			avgY += float64(p.Value)
+		}
+		n := float64(avgEnd - avgStart)
+		avgX /= n
+		avgY /= n
+
+		// Point in current bucket forming the largest triangle with previous point and average
+		start := int(math.Floor(float64(i)*every)) + 1
+		end := int(math.Floor(float64(i+1)*every)) + 1
+		ax, ay := float64(data[a].Timestamp), float64(data[a].Value)
+		maxArea := -1.0
+		next := start
+		for j := start; j < end; j++ {
+			area := math.Abs((ax-avgX)*(float64(data[j].Value)-ay) - (ax-float64(data[j].Timestamp))*(avgY-ay))
+			if area > maxArea {
+				maxArea = area
+				next = j
+			}
+		}
+		sampled = append(sampled, data[next])
+		a = next
+	}
+
+	return append(sampled, data[len(data)-1])
+}
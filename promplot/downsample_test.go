@@ -0,0 +1,56 @@
+package promplot
+
+import (
+	"math"
+	"testing"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestDownsample(t *testing.T) {
+	sine := &model.SampleStream{Metric: model.Metric{"series": "sine"}}
+	for i := 0; i < 1000; i++ {
+		sine.Values = append(sine.Values, model.SamplePair{
+			Timestamp: model.Time(i * 1000),
+			Value:     model.SampleValue(math.Sin(float64(i) / 50)),
+		})
+	}
+	short := testMatrix(1)[0]
+
+	tests := []struct {
+		maxPoints int
+		points    []int
+	}{
+		{maxPoints: 100, points: []int{100, 5}},
+		{maxPoints: 1000, points: []int{1000, 5}},
+		{maxPoints: 3, points: []int{3, 3}},
+		{maxPoints: 1, points: []int{3, 3}},
+	}
+
+	for i, tt := range tests {
+		m := Downsample(model.Matrix{sine, short}, tt.maxPoints)
+		for s, n := range tt.points {
+			values := m[s].Values
+			if len(values) != n {
+				t.Errorf("%d. series %d: expected %d points, got %d", i, s, n, len(values))
+				continue
+			}
+			orig := []*model.SampleStream{sine, short}[s].Values
+			if values[0] != orig[0] || values[n-1] != orig[len(orig)-1] {
+				t.Errorf("%d. series %d: first and last point should be kept", i, s)
+			}
+		}
+	}
+
+	// Peaks should be preserved
+	m := Downsample(model.Matrix{sine}, 100)
+	var max model.SampleValue
+	for _, v := range m[0].Values {
+		if v.Value > max {
+			max = v.Value
+		}
+	}
+	if max < 0.99 {
+		t.Errorf("expected peak to be preserved, max is %v", max)
+	}
+}
@@ -0,0 +1,161 @@
+package promplot
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPConfig holds the settings for sending mails.
+type SMTPConfig struct {
+	Host string
+	Port int
+	// Username and Password are used for authentication if set.
+	Username string
+	Password string
+	From     string
+	To       []string
+	// Filename of the attached plot. Defaults to "promplot".
+	Filename string
+}
+
+// Email sends a plot as mail attachment.
+// Errors wrap ErrUpload.
+func Email(cfg SMTPConfig, title string, plot io.WriterTo) error {
+	return EmailContext(context.Background(), cfg, title, plot)
+}
+
+// EmailContext sends a plot as mail attachment like Email.
+// Connecting is aborted when ctx is canceled, the deadline of ctx also applies to the whole SMTP session.
+func EmailContext(ctx context.Context, cfg SMTPConfig, title string, plot io.WriterTo) (err error) {
+	defer wrap(ErrUpload, &err)
+	defer func() {
+		// Timeouts of the connection can occur just before ctx is done
+		if deadline, ok := ctx.Deadline(); ok && err != nil && !time.Now().Before(deadline) {
+			err = fmt.Errorf("sending mail exceeded the deadline: %w", err)
+		}
+	}()
+	if len(cfg.To) == 0 {
+		return fmt.Errorf("no mail recipients")
+	}
+	if cfg.Filename == "" {
+		cfg.Filename = "promplot"
+	}
+
+	msg, err := mailMessage(cfg, title, plot)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	// The smtp client has no context, a stalled server is cut off by the deadline of the connection
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: cfg.Host}); err != nil {
+			return fmt.Errorf("failed to start tls: %w", err)
+		}
+	}
+	if cfg.Username != "" {
+		if err = c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("smtp authentication failed: %w", err)
+		}
+	}
+
+	if err = c.Mail(cfg.From); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+	for _, to := range cfg.To {
+		if err = c.Rcpt(to); err != nil {
+			return fmt.Errorf("failed to set recipient %s: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start mail data: %w", err)
+	}
+	if _, err = w.Write(msg); err != nil {
+		return fmt.Errorf("failed to write mail: %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+
+	return c.Quit()
+}
+
+// mailMessage creates a multipart message with the plot as attachment.
+func mailMessage(cfg SMTPConfig, title string, plot io.WriterTo) ([]byte, error) {
+	var img bytes.Buffer
+	if _, err := plot.WriteTo(&img); err != nil {
+		return nil, fmt.Errorf("failed to write plot to buffer: %w", err)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	text, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"text/plain; charset=utf-8"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mail body: %w", err)
+	}
+	fmt.Fprintf(text, "%s\r\n", title)
+
+	attachment, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {http.DetectContentType(img.Bytes())},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": cfg.Filename})},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mail attachment: %w", err)
+	}
+	// Split base64 into lines as required by RFC 2045
+	encoded := base64.StdEncoding.EncodeToString(img.Bytes())
+	for len(encoded) > 76 {
+		fmt.Fprintf(attachment, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(attachment, "%s\r\n", encoded)
+
+	if err = mw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close mail body: %w", err)
+	}
+
+	var msg bytes.Buffer
+	header := []string{
+		"From: " + cfg.From,
+		"To: " + strings.Join(cfg.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", title),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/mixed; boundary=" + mw.Boundary(),
+	}
+	msg.WriteString(strings.Join(header, "\r\n") + "\r\n\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
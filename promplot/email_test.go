@@ -0,0 +1,60 @@
+package promplot
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"testing"
+)
+
+func TestMailMessage(t *testing.T) {
+	img := []byte("\x89PNG\r\n\x1a\nimage data")
+	msg, err := mailMessage(SMTPConfig{
+		From:     "promplot@example.com",
+		To:       []string{"a@example.com", "b@example.com"},
+		Filename: "promplot.png",
+	}, "Open file descriptors", bytes.NewBuffer(img))
+	if err != nil {
+		t.Fatalf("creating message failed unexpectedly: %v", err)
+	}
+
+	m, err := mail.ReadMessage(bytes.NewReader(msg))
+	if err != nil {
+		t.Fatalf("invalid message: %v", err)
+	}
+	if s := m.Header.Get("Subject"); s != "Open file descriptors" {
+		t.Errorf("unexpected subject: %s", s)
+	}
+	if to := m.Header.Get("To"); to != "a@example.com, b@example.com" {
+		t.Errorf("unexpected recipients: %s", to)
+	}
+
+	_, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("invalid content type: %v", err)
+	}
+	r := multipart.NewReader(m.Body, params["boundary"])
+	if _, err = r.NextPart(); err != nil {
+		t.Fatalf("missing text part: %v", err)
+	}
+	p, err := r.NextPart()
+	if err != nil {
+		t.Fatalf("missing attachment: %v", err)
+	}
+	if ct := p.Header.Get("Content-Type"); ct != "image/png" {
+		t.Errorf("unexpected attachment type: %s", ct)
+	}
+	if p.FileName() != "promplot.png" {
+		t.Errorf("unexpected attachment name: %s", p.FileName())
+	}
+	data, err := ioutil.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+	if err != nil {
+		t.Fatalf("failed to read attachment: %v", err)
+	}
+	if !bytes.Equal(data, img) {
+		t.Errorf("unexpected attachment content: %q", data)
+	}
+}
@@ -0,0 +1,42 @@
+package promplot
+
+import "errors"
+
+// Errors returned by this package wrap one of these values
+// so callers can distinguish failure causes using errors.Is.
+var (
+	// ErrQuery is returned when fetching data from Prometheus fails.
+	ErrQuery = errors.New("query failed")
+	// ErrEmptyResult is returned when a query matches no data.
+	ErrEmptyResult = errors.New("query returned no data")
+	// ErrPlot is returned when creating a plot fails.
+	ErrPlot = errors.New("plot failed")
+	// ErrUpload is returned when delivering a plot fails.
+	ErrUpload = errors.New("upload failed")
+)
+
+// kindError marks an error as one of the sentinel errors without changing its message.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e kindError) Error() string { return e.err.Error() }
+
+func (e kindError) Unwrap() error { return e.err }
+
+func (e kindError) Is(target error) bool { return target == e.kind }
+
+// wrap marks a non-nil *err as kind unless it is already marked with any of the sentinel errors.
+// It is meant to be deferred with a named error result.
+func wrap(kind error, err *error) {
+	if *err == nil {
+		return
+	}
+	for _, k := range []error{ErrQuery, ErrEmptyResult, ErrPlot, ErrUpload} {
+		if errors.Is(*err, k) {
+			return
+		}
+	}
+	*err = kindError{kind: kind, err: *err}
+}
@@ -0,0 +1,121 @@
+package promplot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.FormValue("query") {
+		case "empty":
+			w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+		}
+	}))
+	defer srv.Close()
+	queryTime := time.Unix(1500000060, 0)
+
+	_, err := Metrics(context.Background(), srv.URL, "broken", queryTime, time.Minute, 1)
+	if !errors.Is(err, ErrQuery) {
+		t.Errorf("expected query error, got: %v", err)
+	}
+
+	m, err := Metrics(context.Background(), srv.URL, "empty", queryTime, time.Minute, 1)
+	if !errors.Is(err, ErrEmptyResult) || errors.Is(err, ErrQuery) {
+		t.Errorf("expected empty result error, got: %v", err)
+	}
+	if len(m) != 0 {
+		t.Errorf("expected empty matrix, got %d series", len(m))
+	}
+
+	_, err = MetricsQueries(context.Background(), srv.URL, []string{"empty", "empty"}, queryTime, time.Minute, 1, MetricsConfig{})
+	if !errors.Is(err, ErrEmptyResult) {
+		t.Errorf("expected empty result error, got: %v", err)
+	}
+
+	_, err = Plot(testMatrix(1), "title", "gif")
+	if !errors.Is(err, ErrPlot) {
+		t.Errorf("expected plot error, got: %v", err)
+	}
+
+	telegramAPI = srv.URL + "/telegram"
+	defer func() { telegramAPI = "https://api.telegram.org" }()
+	err = Telegram("token", "1", "title", bytes.NewBufferString("plot"))
+	if !errors.Is(err, ErrUpload) {
+		t.Errorf("expected upload error, got: %v", err)
+	}
+}
+
+func TestUploadDeadline(t *testing.T) {
+	// Both servers accept requests but never answer
+	stalled := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-stalled
+	}))
+	defer srv.Close()
+	defer close(stalled)
+	smtpServer, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer smtpServer.Close()
+	go func() {
+		for {
+			conn, err := smtpServer.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	smtpPort := smtpServer.Addr().(*net.TCPAddr).Port
+
+	defer setS3Credentials()()
+	s3Endpoint = srv.URL
+	defer func() { s3Endpoint = "" }()
+	telegramAPI = srv.URL
+	defer func() { telegramAPI = "https://api.telegram.org" }()
+
+	uploads := map[string]func(ctx context.Context) error{
+		"s3": func(ctx context.Context) error {
+			return S3Context(ctx, "plots", "up.png", "eu-west-1", bytes.NewBufferString("plot"))
+		},
+		"telegram": func(ctx context.Context) error {
+			return TelegramContext(ctx, "token", "42", "title", bytes.NewBufferString("plot"))
+		},
+		"discord": func(ctx context.Context) error {
+			return DiscordContext(ctx, srv.URL, "title", bytes.NewBufferString("plot"))
+		},
+		"webhook": func(ctx context.Context) error {
+			return WebhookContext(ctx, srv.URL, "title", bytes.NewBufferString("plot"), WebhookConfig{})
+		},
+		"email": func(ctx context.Context) error {
+			cfg := SMTPConfig{Host: "127.0.0.1", Port: smtpPort, From: "promplot@example.com", To: []string{"ops@example.com"}}
+			return EmailContext(ctx, cfg, "title", bytes.NewBufferString("plot"))
+		},
+	}
+
+	for name, upload := range uploads {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		start := time.Now()
+		err := upload(ctx)
+		cancel()
+		if err == nil || !strings.Contains(err.Error(), "exceeded the deadline") || !errors.Is(err, ErrUpload) {
+			t.Errorf("%s: expected upload error about the deadline, got: %v", name, err)
+		}
+		if took := time.Since(start); took > 5*time.Second {
+			t.Errorf("%s: upload should stop at the deadline, took %v", name, took)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package promplot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// ErrExemplarsUnsupported is returned by Exemplars if the server does not offer the exemplar API.
+var ErrExemplarsUnsupported = errors.New("exemplars not supported by server")
+
+// Exemplar is a single observation attached to a series, usually linking to a trace.
+type Exemplar struct {
+	// SeriesLabels are the labels of the series the exemplar belongs to.
+	SeriesLabels model.LabelSet
+	// Labels of the exemplar itself, e.g. trace_id.
+	Labels model.LabelSet
+	Value  float64
+	Time   time.Time
+}
+
+// exemplarResponse is the body of a response of the query_exemplars API.
+type exemplarResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   []struct {
+		SeriesLabels model.LabelSet `json:"seriesLabels"`
+		Exemplars    []struct {
+			Labels    model.LabelSet    `json:"labels"`
+			Value     model.SampleValue `json:"value"`
+			Timestamp model.Time        `json:"timestamp"`
+		} `json:"exemplars"`
+	} `json:"data"`
+}
+
+// Exemplars fetches the exemplars of all series selected by query in the range ending at queryTime.
+// It uses the query_exemplars API of Prometheus 2.26 and later.
+// The connection settings of cfg apply like for MetricsWithConfig.
+// Errors wrap ErrQuery, and ErrExemplarsUnsupported if the server does not know the API.
+func Exemplars(ctx context.Context, server, query string, queryTime time.Time, duration time.Duration, cfg MetricsConfig) (exemplars []Exemplar, err error) {
+	defer wrap(ErrQuery, &err)
+	client, err := newClient(server, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	u := client.URL("/api/v1/query_exemplars", nil)
+	u.RawQuery = url.Values{
+		"query": {query},
+		"start": {apiTime(queryTime.Add(-duration))},
+		"end":   {apiTime(queryTime)},
+	}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exemplar request: %w", err)
+	}
+	resp, body, err := client.Do(ctx, req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("exemplar query exceeded the deadline: %w", err)
+		}
+		return nil, fmt.Errorf("failed to query exemplars: %w", err)
+	}
+	// Older versions and other servers without exemplar storage do not know the endpoint
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusNotImplemented {
+		return nil, fmt.Errorf("%w: %s", ErrExemplarsUnsupported, resp.Status)
+	}
+
+	var result exemplarResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		if resp.StatusCode/100 != 2 {
+			return nil, fmt.Errorf("exemplar query failed with %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		return nil, fmt.Errorf("failed to decode exemplar response: %w", err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("exemplar query failed with %s: %s", resp.Status, result.Error)
+	}
+	for _, series := range result.Data {
+		for _, e := range series.Exemplars {
+			exemplars = append(exemplars, Exemplar{
+				SeriesLabels: series.SeriesLabels,
+				Labels:       e.Labels,
+				Value:        float64(e.Value),
+				Time:         e.Timestamp.Time(),
+			})
+		}
+	}
+	return exemplars, nil
+}
+
+// apiTime formats t as seconds since the Unix epoch like the Prometheus API expects.
+func apiTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', -1, 64)
+}
@@ -0,0 +1,101 @@
+package promplot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestExemplars(t *testing.T) {
+	const found = `{"status":"success","data":[{"seriesLabels":{"__name__":"latency_bucket","le":"0.5"},"exemplars":[
+		{"labels":{"trace_id":"abc"},"value":"0.42","timestamp":1700000000.5},
+		{"labels":{"trace_id":"def"},"value":"0.31","timestamp":1700000060}]}]}`
+	tests := []struct {
+		status      int
+		body        string
+		exemplars   []Exemplar
+		err         string
+		unsupported bool
+	}{
+		{status: http.StatusOK, body: found, exemplars: []Exemplar{
+			{
+				SeriesLabels: model.LabelSet{"__name__": "latency_bucket", "le": "0.5"},
+				Labels:       model.LabelSet{"trace_id": "abc"},
+				Value:        0.42,
+				Time:         time.Unix(1700000000, 5e8),
+			},
+			{
+				SeriesLabels: model.LabelSet{"__name__": "latency_bucket", "le": "0.5"},
+				Labels:       model.LabelSet{"trace_id": "def"},
+				Value:        0.31,
+				Time:         time.Unix(1700000060, 0),
+			},
+		}},
+		{status: http.StatusOK, body: `{"status":"success","data":[]}`},
+		{status: http.StatusNotFound, body: "404 page not found", err: "exemplars not supported", unsupported: true},
+		{status: http.StatusBadRequest, body: `{"status":"error","errorType":"bad_data","error":"parse error"}`, err: "400 Bad Request: parse error"},
+		{status: http.StatusBadGateway, body: "bad gateway", err: "502 Bad Gateway: bad gateway"},
+	}
+
+	for i, tt := range tests {
+		var query string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/v1/query_exemplars" {
+				query = r.URL.RawQuery
+			}
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+		exemplars, err := Exemplars(context.Background(), srv.URL, "latency_bucket", time.Unix(1700003600, 0), time.Hour, MetricsConfig{})
+		srv.Close()
+		if query != "end=1700003600&query=latency_bucket&start=1700000000" {
+			t.Errorf("%d. unexpected query %q", i, query)
+		}
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%d. expected error containing %q, got: %v", i, tt.err, err)
+			}
+			if !errors.Is(err, ErrQuery) {
+				t.Errorf("%d. error should wrap ErrQuery: %v", i, err)
+			}
+			if errors.Is(err, ErrExemplarsUnsupported) != tt.unsupported {
+				t.Errorf("%d. expected unsupported to be %v, got: %v", i, tt.unsupported, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d. query failed unexpectedly: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(exemplars, tt.exemplars) {
+			t.Errorf("%d. expected %v, got %v", i, tt.exemplars, exemplars)
+		}
+	}
+}
+
+func TestPlotExemplars(t *testing.T) {
+	render := func(exemplars []Exemplar) []byte {
+		plot, err := PlotWithOptions(testMatrix(1), PlotOptions{Format: "svg", Exemplars: exemplars})
+		if err != nil {
+			t.Fatalf("plot failed unexpectedly: %v", err)
+		}
+		var buf bytes.Buffer
+		if _, err := plot.WriteTo(&buf); err != nil {
+			t.Fatalf("writing failed unexpectedly: %v", err)
+		}
+		return buf.Bytes()
+	}
+	samples := testMatrix(1)[0].Values
+	e := Exemplar{Time: samples[0].Timestamp.Time(), Value: float64(samples[0].Value)}
+	if bytes.Equal(render(nil), render([]Exemplar{e})) {
+		t.Error("exemplars should be drawn")
+	}
+}
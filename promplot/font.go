@@ -0,0 +1,68 @@
+package promplot
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/golang/freetype/truetype"
+	"gonum.org/v1/plot/vg"
+)
+
+// Bold variants of the built-in fonts used for the title
+var boldFonts = map[string]string{
+	"Courier":     "Courier-Bold",
+	"Helvetica":   "Helvetica-Bold",
+	"Times-Roman": "Times-Bold",
+}
+
+// Fonts returns the sorted names of the fonts built into gonum.
+func Fonts() []string {
+	var names []string
+	for n := range vg.FontMap {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func makeFont(name string, size vg.Length) (vg.Font, error) {
+	font, err := vg.MakeFont(name, size)
+	if err != nil {
+		return font, fmt.Errorf("failed to load font %q, available fonts are: %s: %w", name, strings.Join(Fonts(), ", "), err)
+	}
+	return font, nil
+}
+
+// validFontFormat checks that a plot in format can draw the font name.
+// The PDF and SVG canvases of gonum only know the built-in fonts and panic on others,
+// SVG can still draw them as outlines with embed.
+func validFontFormat(name, format string, embed bool) error {
+	if _, ok := vg.FontMap[name]; ok {
+		return nil
+	}
+	switch {
+	case format == "pdf":
+		return fmt.Errorf("font %q cannot be used for PDF, only the built-in fonts are supported: %s", name, strings.Join(Fonts(), ", "))
+	case format == "svg" && !embed:
+		return fmt.Errorf("font %q can only be used for SVG with embedded fonts", name)
+	}
+	return nil
+}
+
+// RegisterFont parses a TrueType font and makes it available under name,
+// for example to be used as PlotOptions.Font.
+func RegisterFont(name string, ttf io.Reader) error {
+	b, err := ioutil.ReadAll(ttf)
+	if err != nil {
+		return fmt.Errorf("failed to read font: %w", err)
+	}
+	font, err := truetype.Parse(b)
+	if err != nil {
+		return fmt.Errorf("failed to parse font %q as TrueType: %w", name, err)
+	}
+	vg.AddFont(name, font)
+	return nil
+}
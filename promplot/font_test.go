@@ -0,0 +1,60 @@
+package promplot
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"gonum.org/v1/plot/vg/fonts"
+)
+
+func TestRegisterFont(t *testing.T) {
+	ttf, err := fonts.Asset("LiberationSerif-Italic.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterFont("Custom", bytes.NewReader(ttf)); err != nil {
+		t.Fatalf("registering font failed unexpectedly: %v", err)
+	}
+	if _, err := PlotWithOptions(testMatrix(2), PlotOptions{Format: "png", Font: "Custom"}); err != nil {
+		t.Errorf("plot with custom font failed unexpectedly: %v", err)
+	}
+
+	// The PDF and SVG canvases only know the built-in fonts
+	tests := []struct {
+		format  string
+		embed   bool
+		invalid bool
+	}{
+		{format: "pdf", invalid: true},
+		{format: "pdf", embed: true, invalid: true},
+		{format: "svg", invalid: true},
+		{format: "svg", embed: true},
+		{format: "eps"},
+	}
+	for _, tt := range tests {
+		plot, err := PlotWithOptions(testMatrix(2), PlotOptions{Format: tt.format, Font: "Custom", EmbedFonts: tt.embed})
+		if tt.invalid {
+			if err == nil || !errors.Is(err, ErrPlot) {
+				t.Errorf("%s, embedded %v: expected plot error, got: %v", tt.format, tt.embed, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s, embedded %v: plot failed unexpectedly: %v", tt.format, tt.embed, err)
+			continue
+		}
+		if _, err := plot.WriteTo(ioutil.Discard); err != nil {
+			t.Errorf("%s, embedded %v: writing failed unexpectedly: %v", tt.format, tt.embed, err)
+		}
+	}
+
+	if err := RegisterFont("Broken", strings.NewReader("not a font")); err == nil {
+		t.Error("registering invalid font should have failed")
+	}
+	if _, err := PlotWithOptions(testMatrix(2), PlotOptions{Format: "png", Font: "Broken"}); err == nil {
+		t.Error("plot with unregistered font should have failed")
+	}
+}
@@ -0,0 +1,51 @@
+package promplot
+
+import (
+	"time"
+
+	"github.com/prometheus/common/model"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// Layout of the time in the freshness footer
+const freshnessFormat = "2006-01-02 15:04 MST"
+
+// latestTime returns the time of the newest sample in metrics.
+// It returns false if there are no samples.
+func latestTime(metrics model.Matrix) (time.Time, bool) {
+	var latest model.Time
+	found := false
+	for _, sample := range metrics {
+		for _, v := range sample.Values {
+			if !found || v.Timestamp.After(latest) {
+				latest, found = v.Timestamp, true
+			}
+		}
+	}
+	return latest.Time(), found
+}
+
+// drawFreshness draws a footer with the time of the newest sample in the style of the legend at the bottom right of c.
+// It returns the area above the footer for the plot, which is all of c if there are no samples.
+func drawFreshness(c draw.Canvas, p *plot.Plot, metrics model.Matrix, loc *time.Location) draw.Canvas {
+	latest, ok := latestTime(metrics)
+	if !ok {
+		return c
+	}
+	// The plot only fills its own area, the footer gets the same background
+	if p.BackgroundColor != nil {
+		c.SetColor(p.BackgroundColor)
+		c.Fill(c.Rectangle.Path())
+		p.BackgroundColor = nil
+	}
+	text := "data as of " + latest.In(loc).Format(freshnessFormat)
+	sty := p.Legend.TextStyle
+	sty.XAlign = draw.XRight
+	sty.YAlign = draw.YBottom
+	c.FillText(sty, vg.Point{X: c.Max.X, Y: c.Min.Y}, text)
+	area := c
+	area.Min.Y += sty.Height(text) + subtitleGap
+	return area
+}
@@ -0,0 +1,71 @@
+package promplot
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestLatestTime(t *testing.T) {
+	// Samples of testSeries are one minute apart starting at 1500000000
+	tests := []struct {
+		metrics model.Matrix
+		latest  int64
+		found   bool
+	}{
+		{metrics: model.Matrix{testSeries(nil, 1, 2, 3)}, latest: 1500000120, found: true},
+		// Series can end at different times
+		{metrics: model.Matrix{testSeries(nil, 1, 2), testSeries(nil, 1, 2, 3, 4), testSeries(nil)}, latest: 1500000180, found: true},
+		{metrics: model.Matrix{testSeries(nil), testSeries(nil)}},
+		{metrics: model.Matrix{}},
+	}
+
+	for i, tt := range tests {
+		latest, found := latestTime(tt.metrics)
+		if found != tt.found {
+			t.Errorf("%d. expected found %v, got %v", i, tt.found, found)
+		}
+		if found && latest.Unix() != tt.latest {
+			t.Errorf("%d. expected latest time %d, got %d", i, tt.latest, latest.Unix())
+		}
+	}
+}
+
+func TestPlotFreshness(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+	tests := []struct {
+		metrics   model.Matrix
+		freshness bool
+		location  *time.Location
+		footer    string
+	}{
+		// The last sample of testMatrix is at 1500000240
+		{metrics: testMatrix(2), freshness: true, footer: "data as of 2017-07-14 02:44 UTC"},
+		{metrics: testMatrix(2), freshness: true, location: berlin, footer: "data as of 2017-07-14 04:44 CEST"},
+		{metrics: testMatrix(2)},
+		{metrics: model.Matrix{{Metric: model.Metric{}}}, freshness: true},
+	}
+
+	for i, tt := range tests {
+		plot, err := PlotWithOptions(tt.metrics, PlotOptions{Format: "svg", Freshness: tt.freshness, Location: tt.location})
+		if err != nil {
+			t.Fatalf("%d. plot failed unexpectedly: %v", i, err)
+		}
+		var buf bytes.Buffer
+		if _, err := plot.WriteTo(&buf); err != nil {
+			t.Fatalf("%d. writing failed unexpectedly: %v", i, err)
+		}
+		if tt.footer != "" && !strings.Contains(buf.String(), ">"+tt.footer+"<") {
+			t.Errorf("%d. expected footer %q in plot", i, tt.footer)
+		}
+		if tt.footer == "" && strings.Contains(buf.String(), "data as of") {
+			t.Errorf("%d. plot should not have a footer", i)
+		}
+	}
+}
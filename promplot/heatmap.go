@@ -0,0 +1,179 @@
+package promplot
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/prometheus/common/model"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/palette/brewer"
+	"gonum.org/v1/plot/plotter"
+)
+
+// Sequential palette used for heatmaps, light for few and dark for many observations
+const heatmapPalette = "YlOrRd"
+
+// Maximum number of bucket labels on the Y axis of heatmaps
+const maxBucketLabels = 10
+
+// histogramGrid holds the number of observations in each bucket of a histogram over time.
+// Columns are timestamps and rows are buckets ordered by their upper bound.
+type histogramGrid struct {
+	times  []float64
+	counts [][]float64
+}
+
+var _ plotter.GridXYZ = histogramGrid{}
+
+func (g histogramGrid) Dims() (c, r int) {
+	if len(g.counts) == 0 {
+		return 0, 0
+	}
+	return len(g.counts), len(g.counts[0])
+}
+
+// Z returns NaN for empty buckets so they are not drawn.
+func (g histogramGrid) Z(c, r int) float64 {
+	if v := g.counts[c][r]; v > 0 {
+		return v
+	}
+	return math.NaN()
+}
+
+func (g histogramGrid) X(c int) float64 { return g.times[c] }
+
+func (g histogramGrid) Y(r int) float64 { return float64(r) }
+
+// newHistogramGrid converts the cumulative bucket series of a histogram to the number of observations per bucket.
+// Series of the same bucket are summed, e.g. from different instances.
+// It returns the grid with the upper bound of each row.
+func newHistogramGrid(metrics model.Matrix) (histogramGrid, []string, error) {
+	buckets := Relabel(metrics, []string{model.BucketLabel}, nil, true)
+	bounds := make([]float64, len(buckets))
+	for i, b := range buckets {
+		le, ok := b.Metric[model.BucketLabel]
+		if !ok {
+			return histogramGrid{}, nil, fmt.Errorf("heatmap requires histogram buckets with an %s label", model.BucketLabel)
+		}
+		v, err := strconv.ParseFloat(string(le), 64)
+		if err != nil {
+			return histogramGrid{}, nil, fmt.Errorf("invalid bucket bound %q: %w", le, err)
+		}
+		bounds[i] = v
+	}
+	sort.Sort(bucketsByBound{buckets, bounds})
+
+	// Buckets can miss timestamps, so every bucket is looked up at all timestamps
+	index := map[model.Time]int{}
+	var times []model.Time
+	for _, b := range buckets {
+		for _, v := range b.Values {
+			if _, ok := index[v.Timestamp]; !ok {
+				index[v.Timestamp] = 0
+				times = append(times, v.Timestamp)
+			}
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	g := histogramGrid{times: make([]float64, len(times)), counts: make([][]float64, len(times))}
+	for c, t := range times {
+		index[t] = c
+		g.times[c] = float64(t.Unix())
+		g.counts[c] = make([]float64, len(buckets))
+	}
+	cumulative := make([][]float64, len(times))
+	for c := range cumulative {
+		cumulative[c] = make([]float64, len(buckets))
+		for r := range buckets {
+			cumulative[c][r] = math.NaN()
+		}
+	}
+	for r, b := range buckets {
+		for _, v := range b.Values {
+			cumulative[index[v.Timestamp]][r] = float64(v.Value)
+		}
+	}
+	for c := range times {
+		below := 0.0
+		for r := range buckets {
+			v := cumulative[c][r]
+			// Counter resets between the buckets can make the difference negative
+			g.counts[c][r] = math.Max(v-below, 0)
+			// Missing buckets are skipped, the next one counts from the last known bucket
+			if !math.IsNaN(v) {
+				below = v
+			}
+		}
+	}
+
+	labels := make([]string, len(buckets))
+	for r, b := range buckets {
+		labels[r] = string(b.Metric[model.BucketLabel])
+	}
+	return g, labels, nil
+}
+
+// bucketsByBound sorts bucket series by their upper bound.
+type bucketsByBound struct {
+	buckets model.Matrix
+	bounds  []float64
+}
+
+func (b bucketsByBound) Len() int           { return len(b.buckets) }
+func (b bucketsByBound) Less(i, j int) bool { return b.bounds[i] < b.bounds[j] }
+func (b bucketsByBound) Swap(i, j int) {
+	b.buckets[i], b.buckets[j] = b.buckets[j], b.buckets[i]
+	b.bounds[i], b.bounds[j] = b.bounds[j], b.bounds[i]
+}
+
+// bucketTicks labels the rows of a heatmap with the upper bound of their bucket.
+type bucketTicks struct {
+	labels []string
+	unit   string
+}
+
+var _ plot.Ticker = bucketTicks{}
+
+func (b bucketTicks) Ticks(min, max float64) []plot.Tick {
+	// Label only every n-th bucket if there are too many
+	n := (len(b.labels) + maxBucketLabels - 1) / maxBucketLabels
+	ticks := make([]plot.Tick, len(b.labels))
+	for r, label := range b.labels {
+		ticks[r].Value = float64(r)
+		if r%n != 0 {
+			continue
+		}
+		if v, err := strconv.ParseFloat(label, 64); err == nil && b.unit != "" && !math.IsInf(v, 0) {
+			label = FormatUnit(v, b.unit)
+		}
+		ticks[r].Label = label
+	}
+	return ticks
+}
+
+// newHeatmap creates a heatmap of histogram buckets and the ticks to label them on the Y axis.
+// The heatmap is nil if there is no data.
+func newHeatmap(metrics model.Matrix, unit string) (*plotter.HeatMap, plot.Ticker, error) {
+	g, labels, err := newHistogramGrid(metrics)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cols, rows := g.Dims(); cols == 0 || rows == 0 {
+		return nil, nil, nil
+	}
+	palette, err := brewer.GetPalette(brewer.TypeSequential, heatmapPalette, 9)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get heatmap palette: %w", err)
+	}
+	h := plotter.NewHeatMap(g, palette)
+	// The heatmap needs a valid range even without observations
+	if h.Min > h.Max {
+		h.Min, h.Max = 0, 1
+	}
+	if h.Min == h.Max {
+		h.Min = 0
+	}
+	return h, bucketTicks{labels: labels, unit: unit}, nil
+}
@@ -0,0 +1,93 @@
+package promplot
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestHistogramGrid(t *testing.T) {
+	nan := math.NaN()
+	metrics := model.Matrix{
+		testSeries(model.Metric{"le": "+Inf", "instance": "a"}, 10, 20),
+		// The second sample of the bucket is missing
+		{Metric: model.Metric{"le": "0.5", "instance": "a"}, Values: []model.SamplePair{{Timestamp: model.TimeFromUnix(1500000000), Value: 8}}},
+		testSeries(model.Metric{"le": "0.1", "instance": "a"}, 1, 4),
+		testSeries(model.Metric{"le": "0.1", "instance": "b"}, 1, 0),
+	}
+	g, labels, err := newHistogramGrid(metrics)
+	if err != nil {
+		t.Fatalf("creating grid failed unexpectedly: %v", err)
+	}
+	if expected := []string{"0.1", "0.5", "+Inf"}; !reflect.DeepEqual(labels, expected) {
+		t.Errorf("expected buckets %v, got %v", expected, labels)
+	}
+	if cols, rows := g.Dims(); cols != 2 || rows != 3 {
+		t.Fatalf("expected 2x3 grid, got %dx%d", cols, rows)
+	}
+	if g.X(0) != 1500000000 || g.X(1) != 1500000060 || g.Y(2) != 2 {
+		t.Errorf("unexpected coordinates %v, %v, %v", g.X(0), g.X(1), g.Y(2))
+	}
+	// Instances are summed and the missing bucket is left empty
+	expected := [][]float64{{2, 6, 2}, {4, nan, 16}}
+	for c := range expected {
+		for r, e := range expected[c] {
+			if z := g.Z(c, r); z != e && !(math.IsNaN(z) && math.IsNaN(e)) {
+				t.Errorf("%d,%d: expected %v, got %v", c, r, e, z)
+			}
+		}
+	}
+
+	for _, m := range []model.Matrix{
+		{testSeries(model.Metric{"job": "a"}, 1)},
+		{testSeries(model.Metric{"le": "fast"}, 1)},
+	} {
+		if _, _, err := newHistogramGrid(m); err == nil {
+			t.Errorf("grid of %v should have failed", m)
+		}
+	}
+}
+
+func TestBucketTicks(t *testing.T) {
+	ticks := bucketTicks{labels: []string{"0.1", "0.5", "+Inf"}, unit: "seconds"}.Ticks(0, 2)
+	var labels []string
+	for _, tick := range ticks {
+		labels = append(labels, tick.Label)
+	}
+	if expected := []string{"100ms", "500ms", "+Inf"}; !reflect.DeepEqual(labels, expected) {
+		t.Errorf("expected labels %v, got %v", expected, labels)
+	}
+
+	many := make([]string, 25)
+	for i := range many {
+		many[i] = "1"
+	}
+	labeled := 0
+	for _, tick := range (bucketTicks{labels: many}).Ticks(0, 24) {
+		if tick.Label != "" {
+			labeled++
+		}
+	}
+	if labeled > maxBucketLabels {
+		t.Errorf("expected at most %d labels, got %d", maxBucketLabels, labeled)
+	}
+}
+
+func TestPlotHeatmap(t *testing.T) {
+	metrics := model.Matrix{
+		testSeries(model.Metric{"le": "0.1"}, 1, 2, 3),
+		testSeries(model.Metric{"le": "+Inf"}, 1, 5, 9),
+	}
+	for _, m := range []model.Matrix{metrics, {}, {testSeries(model.Metric{"le": "1"}, 0, 0)}} {
+		if _, err := PlotWithOptions(m, PlotOptions{Format: "png", Heatmap: true}); err != nil {
+			t.Errorf("heatmap of %v failed unexpectedly: %v", m, err)
+		}
+	}
+	_, err := PlotWithOptions(metrics, PlotOptions{Format: "png", Heatmap: true, LogY: true})
+	if err == nil || !strings.Contains(err.Error(), "heatmap cannot be combined") {
+		t.Errorf("heatmap with logarithmic axis should fail, got: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package promplot
+
+import "github.com/prometheus/common/model"
+
+// Relabel returns a copy of metrics with only the keep labels of each series and without the drop labels.
+// An empty keep list keeps all labels.
+// Series which end up with the same labels are summed up at each timestamp if merge is set, like sum by in PromQL.
+// Otherwise they stay separate series.
+func Relabel(metrics model.Matrix, keep, drop []string, merge bool) model.Matrix {
+	relabeled := make(model.Matrix, 0, len(metrics))
+	// Merged series are ordered by their first occurrence
+	same := map[model.Fingerprint][]*model.SampleStream{}
+	var first []*model.SampleStream
+	for _, sample := range metrics {
+		metric := relabelMetric(sample.Metric, keep, drop)
+		s := &model.SampleStream{Metric: metric, Values: sample.Values}
+		if !merge {
+			relabeled = append(relabeled, s)
+			continue
+		}
+		fp := metric.Fingerprint()
+		if _, ok := same[fp]; !ok {
+			first = append(first, s)
+		}
+		same[fp] = append(same[fp], s)
+	}
+	for _, s := range first {
+		group := same[s.Metric.Fingerprint()]
+		if len(group) == 1 {
+			relabeled = append(relabeled, s)
+			continue
+		}
+		relabeled = append(relabeled, sumSeries(group, s.Metric))
+	}
+	return relabeled
+}
+
+// relabelMetric returns a copy of metric with only the keep labels and without the drop labels.
+func relabelMetric(metric model.Metric, keep, drop []string) model.Metric {
+	relabeled := make(model.Metric, len(metric))
+	if len(keep) == 0 {
+		for name, value := range metric {
+			relabeled[name] = value
+		}
+	}
+	for _, name := range keep {
+		if value, ok := metric[model.LabelName(name)]; ok {
+			relabeled[model.LabelName(name)] = value
+		}
+	}
+	for _, name := range drop {
+		delete(relabeled, model.LabelName(name))
+	}
+	return relabeled
+}
@@ -0,0 +1,86 @@
+package promplot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestRelabel(t *testing.T) {
+	metrics := model.Matrix{
+		testSeries(model.Metric{"__name__": "up", "job": "api", "instance": "a:80"}, 1),
+		testSeries(model.Metric{"__name__": "up", "job": "api", "instance": "b:80"}, 2),
+		testSeries(model.Metric{"__name__": "up", "job": "web", "instance": "c:80"}, 4),
+	}
+
+	tests := []struct {
+		name       string
+		keep, drop []string
+		merge      bool
+		expected   model.Matrix
+	}{
+		{
+			name:     "unchanged",
+			expected: metrics,
+		},
+		{
+			name: "drop",
+			drop: []string{"instance", "missing"},
+			expected: model.Matrix{
+				testSeries(model.Metric{"__name__": "up", "job": "api"}, 1),
+				testSeries(model.Metric{"__name__": "up", "job": "api"}, 2),
+				testSeries(model.Metric{"__name__": "up", "job": "web"}, 4),
+			},
+		},
+		{
+			name: "keep",
+			keep: []string{"instance", "missing"},
+			expected: model.Matrix{
+				testSeries(model.Metric{"instance": "a:80"}, 1),
+				testSeries(model.Metric{"instance": "b:80"}, 2),
+				testSeries(model.Metric{"instance": "c:80"}, 4),
+			},
+		},
+		{
+			name: "keep and drop",
+			keep: []string{"job", "instance"},
+			drop: []string{"instance"},
+			expected: model.Matrix{
+				testSeries(model.Metric{"job": "api"}, 1),
+				testSeries(model.Metric{"job": "api"}, 2),
+				testSeries(model.Metric{"job": "web"}, 4),
+			},
+		},
+		{
+			name:  "merge",
+			keep:  []string{"job"},
+			merge: true,
+			expected: model.Matrix{
+				testSeries(model.Metric{"job": "api"}, 3),
+				testSeries(model.Metric{"job": "web"}, 4),
+			},
+		},
+		{
+			name:  "merge all",
+			keep:  []string{"missing"},
+			merge: true,
+			expected: model.Matrix{
+				testSeries(model.Metric{}, 7),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relabeled := Relabel(metrics, tt.keep, tt.drop, tt.merge)
+			if !reflect.DeepEqual(relabeled, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, relabeled)
+			}
+		})
+	}
+
+	if metrics[0].Metric["instance"] != "a:80" {
+		t.Error("input should not be modified")
+	}
+}
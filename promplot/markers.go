@@ -0,0 +1,178 @@
+package promplot
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/palette/brewer"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// Muted palette used for drawing marker lines
+const markerPalette = "Set2"
+
+// HLine is a horizontal reference line drawn across the whole plot.
+type HLine struct {
+	Value float64
+	// Label is drawn at the right edge of the line. Optional.
+	Label string
+}
+
+// ParseHLine parses a horizontal line in the format "value[:label]".
+func ParseHLine(s string) (HLine, error) {
+	value, label := s, ""
+	if i := strings.Index(s, ":"); i >= 0 {
+		value, label = s[:i], s[i+1:]
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return HLine{}, fmt.Errorf("invalid value of horizontal line %q: %w", s, err)
+	}
+	return HLine{Value: v, Label: label}, nil
+}
+
+// VLine is a vertical marker line drawn at a point in time, e.g. a deploy.
+type VLine struct {
+	Time time.Time
+	// Label is drawn near the top of the line. Optional.
+	Label string
+}
+
+// ParseVLine parses a vertical line in the format "RFC3339[:label]".
+func ParseVLine(s string) (VLine, error) {
+	// The timestamp itself contains colons so try every possible split
+	for i := 0; i < len(s); i++ {
+		if s[i] != ':' {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, s[:i]); err == nil {
+			return VLine{Time: t, Label: s[i+1:]}, nil
+		}
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return VLine{}, fmt.Errorf("invalid time of vertical line %q: %w", s, err)
+	}
+	return VLine{Time: t}, nil
+}
+
+// addMarkers adds dashed horizontal lines spanning the X range of the plotted data
+// and vertical lines spanning the resulting or fixed Y range.
+// Vertical lines outside of the X range are skipped and reported to opts.Logf. Fixed ranges take precedence over the data.
+// Nothing is drawn if there is no data.
+func addMarkers(p *plot.Plot, opts PlotOptions, font vg.Font) error {
+	hLines, vLines := opts.HLines, opts.VLines
+	if len(hLines)+len(vLines) == 0 || p.X.Min > p.X.Max {
+		return nil
+	}
+	palette, err := brewer.GetPalette(brewer.TypeQualitative, markerPalette, 8)
+	if err != nil {
+		return fmt.Errorf("failed to get marker palette: %w", err)
+	}
+	colors := palette.Colors()
+
+	minX, maxX := p.X.Min, p.X.Max
+	if !opts.XMin.IsZero() {
+		minX = float64(opts.XMin.Unix())
+	}
+	if !opts.XMax.IsZero() {
+		maxX = float64(opts.XMax.Unix())
+	}
+	for i, h := range hLines {
+		c := colors[i%len(colors)]
+		if err := addMarker(p, plotter.XYs{{X: minX, Y: h.Value}, {X: maxX, Y: h.Value}}, c, h.Label, font, draw.XRight, draw.YBottom); err != nil {
+			return err
+		}
+	}
+
+	// Y range includes the horizontal lines now
+	minY, maxY := p.Y.Min, p.Y.Max
+	if opts.YMin != nil {
+		minY = *opts.YMin
+	}
+	if opts.YMax != nil {
+		maxY = *opts.YMax
+	}
+	for i, v := range vLines {
+		x := float64(v.Time.Unix())
+		if x < minX || x > maxX {
+			if opts.Logf != nil {
+				opts.Logf("skipping vertical line at %s outside of the plotted range", v.Time.In(opts.Location).Format(time.RFC3339))
+			}
+			continue
+		}
+		c := colors[(len(hLines)+i)%len(colors)]
+		if err := addMarker(p, plotter.XYs{{X: x, Y: minY}, {X: x, Y: maxY}}, c, v.Label, font, draw.XLeft, draw.YTop); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addMarker adds a dashed line between two points.
+// The optional label is drawn at the second point with the given alignment.
+func addMarker(p *plot.Plot, xys plotter.XYs, c color.Color, label string, font vg.Font, xAlign draw.XAlignment, yAlign draw.YAlignment) error {
+	l, err := plotter.NewLine(xys)
+	if err != nil {
+		return fmt.Errorf("failed to create line: %w", err)
+	}
+	l.LineStyle.Width = vg.Points(0.75)
+	l.LineStyle.Dashes = []vg.Length{vg.Points(4), vg.Points(2)}
+	l.LineStyle.Color = c
+	p.Add(l)
+
+	if label == "" {
+		return nil
+	}
+	labels, err := plotter.NewLabels(plotter.XYLabels{
+		XYs:    xys[len(xys)-1:],
+		Labels: []string{label},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create label: %w", err)
+	}
+	labels.TextStyle[0].Font = font
+	labels.TextStyle[0].Color = c
+	labels.TextStyle[0].XAlign = xAlign
+	labels.TextStyle[0].YAlign = yAlign
+	if xAlign == draw.XLeft {
+		labels.XOffset = vg.Millimeter
+	}
+	p.Add(labels)
+	return nil
+}
+
+// EventLines creates vertical lines at all non-zero points of metrics,
+// e.g. the result of a query like changes(deploy_timestamp[1m]).
+// Each line is labeled with its time using layout in loc.
+// Labels are single lines, line breaks of layouts for axis ticks like DefaultTimeFormat are replaced with spaces.
+// Points of multiple series at the same time result in a single line.
+func EventLines(metrics model.Matrix, layout string, loc *time.Location) []VLine {
+	layout = strings.Replace(layout, "\n", " ", -1)
+	seen := map[model.Time]bool{}
+	var lines []VLine
+	for _, sample := range metrics {
+		for _, v := range sample.Values {
+			f := float64(v.Value)
+			if f == 0 || math.IsNaN(f) || seen[v.Timestamp] {
+				continue
+			}
+			seen[v.Timestamp] = true
+			t := v.Timestamp.Time().In(loc)
+			lines = append(lines, VLine{Time: t, Label: t.Format(layout)})
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i].Time.Before(lines[j].Time)
+	})
+	return lines
+}
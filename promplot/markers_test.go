@@ -0,0 +1,173 @@
+package promplot
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestParseHLine(t *testing.T) {
+	tests := []struct {
+		in      string
+		line    HLine
+		invalid bool
+	}{
+		{in: "0.999", line: HLine{Value: 0.999}},
+		{in: "-5:min", line: HLine{Value: -5, Label: "min"}},
+		{in: "100:budget: 100ms", line: HLine{Value: 100, Label: "budget: 100ms"}},
+		{in: "1e3:", line: HLine{Value: 1000}},
+		{in: "", invalid: true},
+		{in: "high:label", invalid: true},
+	}
+
+	for i, tt := range tests {
+		line, err := ParseHLine(tt.in)
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("%d. parsing '%s' failed unexpectedly: %v", i, tt.in, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("%d. parsing '%s' should have failed", i, tt.in)
+			continue
+		}
+		if line != tt.line {
+			t.Errorf("%d. expected %+v, got %+v", i, tt.line, line)
+		}
+	}
+}
+
+func TestPlotHLines(t *testing.T) {
+	opts, err := PlotOptions{Format: "png", HLines: []HLine{{Value: 100, Label: "max"}, {Value: -1}}}.withDefaults()
+	if err != nil {
+		t.Fatalf("options failed unexpectedly: %v", err)
+	}
+	p, err := newPlot(testMatrix(2), opts)
+	if err != nil {
+		t.Fatalf("plot failed unexpectedly: %v", err)
+	}
+	// Lines outside of the data extend the Y axis
+	if p.Y.Min > -1 || p.Y.Max < 100 {
+		t.Errorf("expected Y axis to include horizontal lines, got [%v, %v]", p.Y.Min, p.Y.Max)
+	}
+
+	// No data to draw lines across
+	if _, err := newPlot(nil, opts); err != nil {
+		t.Errorf("plot without data failed unexpectedly: %v", err)
+	}
+}
+
+func TestParseVLine(t *testing.T) {
+	tests := []struct {
+		in      string
+		line    VLine
+		invalid bool
+	}{
+		{in: "2017-07-14T02:40:00Z", line: VLine{Time: time.Unix(1500000000, 0)}},
+		{in: "2017-07-14T02:40:00Z:deploy", line: VLine{Time: time.Unix(1500000000, 0), Label: "deploy"}},
+		{in: "2017-07-14T04:40:00+02:00:v1:2", line: VLine{Time: time.Unix(1500000000, 0), Label: "v1:2"}},
+		{in: "2017-07-14", invalid: true},
+		{in: "deploy:2017-07-14T02:40:00Z", invalid: true},
+	}
+
+	for i, tt := range tests {
+		line, err := ParseVLine(tt.in)
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("%d. parsing '%s' failed unexpectedly: %v", i, tt.in, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("%d. parsing '%s' should have failed", i, tt.in)
+			continue
+		}
+		if !line.Time.Equal(tt.line.Time) || line.Label != tt.line.Label {
+			t.Errorf("%d. expected %+v, got %+v", i, tt.line, line)
+		}
+	}
+}
+
+func TestPlotVLines(t *testing.T) {
+	opts, err := PlotOptions{Format: "png", VLines: []VLine{
+		{Time: time.Unix(1500000060, 0), Label: "deploy"},
+		{Time: time.Unix(1400000000, 0)},
+	}}.withDefaults()
+	if err != nil {
+		t.Fatalf("options failed unexpectedly: %v", err)
+	}
+	p, err := newPlot(testMatrix(2), opts)
+	if err != nil {
+		t.Fatalf("plot failed unexpectedly: %v", err)
+	}
+	// Lines outside of the data do not extend the X axis
+	if p.X.Min != 1500000000 || p.X.Max != 1500000240 {
+		t.Errorf("expected X axis to only cover the data, got [%v, %v]", p.X.Min, p.X.Max)
+	}
+}
+
+func TestPlotVLinesSkipped(t *testing.T) {
+	// Data of testMatrix(2) is from 1500000000 to 1500000240
+	tests := []struct {
+		xMax    time.Time
+		skipped []string
+	}{
+		{skipped: []string{"2014-05-13T16:53:20Z"}},
+		// Fixed ranges take precedence over the data
+		{xMax: time.Unix(1500000030, 0), skipped: []string{"2017-07-14T02:41:00Z", "2014-05-13T16:53:20Z"}},
+	}
+
+	for i, tt := range tests {
+		var skipped []string
+		opts, err := PlotOptions{Format: "png", XMax: tt.xMax, VLines: []VLine{
+			{Time: time.Unix(1500000060, 0), Label: "deploy"},
+			{Time: time.Unix(1400000000, 0)},
+		}, Logf: func(format string, a ...interface{}) {
+			skipped = append(skipped, a[0].(string))
+		}}.withDefaults()
+		if err != nil {
+			t.Fatalf("%d. options failed unexpectedly: %v", i, err)
+		}
+		if _, err := newPlot(testMatrix(2), opts); err != nil {
+			t.Fatalf("%d. plot failed unexpectedly: %v", i, err)
+		}
+		if !reflect.DeepEqual(skipped, tt.skipped) {
+			t.Errorf("%d. expected skipped lines %v, got %v", i, tt.skipped, skipped)
+		}
+	}
+}
+
+func TestEventLines(t *testing.T) {
+	metrics := model.Matrix{
+		testSeries(model.Metric{"app": "a"}, 0, 0, 1, 0, math.NaN()),
+		testSeries(model.Metric{"app": "b"}, 2, 0, 1, 0, 0),
+	}
+
+	lines := EventLines(metrics, "15:04", time.UTC)
+	expected := []VLine{
+		{Time: time.Unix(1500000000, 0), Label: "02:40"},
+		{Time: time.Unix(1500000120, 0), Label: "02:42"},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %v", len(expected), lines)
+	}
+	for i := range expected {
+		if !lines[i].Time.Equal(expected[i].Time) || lines[i].Label != expected[i].Label {
+			t.Errorf("%d. expected %+v, got %+v", i, expected[i], lines[i])
+		}
+	}
+
+	// Layouts of axis ticks can span multiple lines
+	lines = EventLines(metrics, DefaultTimeFormat, time.UTC)
+	if len(lines) == 0 || lines[0].Label != "2017-07-14 02:40" {
+		t.Errorf("expected single line label, got %v", lines)
+	}
+
+	if lines := EventLines(nil, "15:04", time.UTC); len(lines) != 0 {
+		t.Errorf("expected no lines without events, got %v", lines)
+	}
+}
@@ -0,0 +1,107 @@
+package promplot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/prometheus/common/model"
+)
+
+// LoadMatrix reads metric data from JSON.
+// Both a complete response of the Prometheus query_range API
+// and only its data.result array are accepted.
+// Vector and scalar results of the query API are converted with ToMatrix.
+func LoadMatrix(r io.Reader) (model.Matrix, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics: %w", err)
+	}
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '[' {
+		var m model.Matrix
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, fmt.Errorf("failed to parse metrics: %w", err)
+		}
+		return m, nil
+	}
+
+	var resp struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType model.ValueType `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse metrics: %w", err)
+	}
+	if resp.Status != "success" {
+		return nil, fmt.Errorf("response has status %q: %s", resp.Status, resp.Error)
+	}
+	var value model.Value
+	switch resp.Data.ResultType {
+	case model.ValMatrix:
+		value = &model.Matrix{}
+	case model.ValVector:
+		value = &model.Vector{}
+	case model.ValScalar:
+		value = &model.Scalar{}
+	default:
+		return nil, fmt.Errorf("unsupported result format: %s", resp.Data.ResultType)
+	}
+	if err := json.Unmarshal(resp.Data.Result, value); err != nil {
+		return nil, fmt.Errorf("failed to parse metrics: %w", err)
+	}
+	return ToMatrix(value)
+}
+
+// ToMatrix converts a query result to a matrix which can be plotted.
+// Each sample of a vector becomes a series with a single point
+// and a scalar becomes a single point without labels.
+func ToMatrix(value model.Value) (model.Matrix, error) {
+	switch v := value.(type) {
+	case model.Matrix:
+		return v, nil
+	case *model.Matrix:
+		return *v, nil
+	case model.Vector:
+		return vectorMatrix(v), nil
+	case *model.Vector:
+		return vectorMatrix(*v), nil
+	case *model.Scalar:
+		return model.Matrix{{
+			Metric: model.Metric{},
+			Values: []model.SamplePair{{Timestamp: v.Timestamp, Value: v.Value}},
+		}}, nil
+	case nil:
+		return nil, fmt.Errorf("missing result")
+	}
+	return nil, fmt.Errorf("unsupported result format: %s", value.Type())
+}
+
+func vectorMatrix(v model.Vector) model.Matrix {
+	m := make(model.Matrix, len(v))
+	for i, sample := range v {
+		m[i] = &model.SampleStream{
+			Metric: sample.Metric,
+			Values: []model.SamplePair{{Timestamp: sample.Timestamp, Value: sample.Value}},
+		}
+	}
+	return m
+}
+
+// DumpMatrix writes metric data as JSON to w.
+// The output can be read again with LoadMatrix.
+func DumpMatrix(m model.Matrix, w io.Writer) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics: %w", err)
+	}
+	if _, err := w.Write(b); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package promplot
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestLoadMatrix(t *testing.T) {
+	tests := []struct {
+		input   string
+		series  int
+		invalid bool
+	}{
+		{input: matrixResponse, series: 1},
+		{input: ` [{"metric":{"job":"a"},"values":[[1500000000,"1"]]},{"metric":{"job":"b"},"values":[]}]`, series: 2},
+		{input: `[]`, series: 0},
+		{input: `{"status":"error","errorType":"bad_data","error":"parse error"}`, invalid: true},
+		{input: `{"status":"success","data":{"resultType":"vector","result":[]}}`, series: 0},
+		{input: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"a"},"value":[1500000000,"1"]},{"metric":{"job":"b"},"value":[1500000000,"2"]}]}}`, series: 2},
+		{input: `{"status":"success","data":{"resultType":"scalar","result":[1500000000,"1"]}}`, series: 1},
+		{input: `{"status":"success","data":{"resultType":"string","result":[1500000000,"up"]}}`, invalid: true},
+		{input: `{"status":"success","data":{"resultType":"vector","result":{}}}`, invalid: true},
+		{input: `{"status":"success","data":`, invalid: true},
+		{input: ``, invalid: true},
+	}
+
+	for i, tt := range tests {
+		m, err := LoadMatrix(strings.NewReader(tt.input))
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("%d. loading failed unexpectedly: %v", i, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("%d. loading should have failed", i)
+			continue
+		}
+		if len(m) != tt.series {
+			t.Errorf("%d. expected %d series, got %d", i, tt.series, len(m))
+		}
+	}
+}
+
+func TestDumpMatrix(t *testing.T) {
+	m := testMatrix(3)
+	var buf bytes.Buffer
+	if err := DumpMatrix(m, &buf); err != nil {
+		t.Fatalf("dumping failed unexpectedly: %v", err)
+	}
+	loaded, err := LoadMatrix(&buf)
+	if err != nil {
+		t.Fatalf("loading failed unexpectedly: %v", err)
+	}
+	if loaded.String() != m.String() {
+		t.Errorf(`
+Expected: %s
+Got       %s`, m, loaded)
+	}
+
+	if err := DumpMatrix(m, failingWriter{}); err == nil {
+		t.Error("dumping to failing writer should have failed")
+	}
+}
+
+func TestToMatrix(t *testing.T) {
+	matrix := testMatrix(2)
+	tests := []struct {
+		name    string
+		value   model.Value
+		matrix  model.Matrix
+		invalid bool
+	}{
+		{name: "matrix", value: matrix, matrix: matrix},
+		{
+			name: "vector",
+			value: model.Vector{
+				{Metric: model.Metric{"job": "a"}, Value: 1, Timestamp: 1000},
+				{Metric: model.Metric{"job": "b"}, Value: 2, Timestamp: 1000},
+			},
+			matrix: model.Matrix{
+				{Metric: model.Metric{"job": "a"}, Values: []model.SamplePair{{Timestamp: 1000, Value: 1}}},
+				{Metric: model.Metric{"job": "b"}, Values: []model.SamplePair{{Timestamp: 1000, Value: 2}}},
+			},
+		},
+		{name: "empty vector", value: model.Vector{}, matrix: model.Matrix{}},
+		{
+			name:   "scalar",
+			value:  &model.Scalar{Value: 3, Timestamp: 1000},
+			matrix: model.Matrix{{Metric: model.Metric{}, Values: []model.SamplePair{{Timestamp: 1000, Value: 3}}}},
+		},
+		{name: "string", value: &model.String{Value: "up", Timestamp: 1000}, invalid: true},
+		{name: "nil", invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ToMatrix(tt.value)
+			if tt.invalid {
+				if err == nil {
+					t.Error("conversion should have failed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("conversion failed unexpectedly: %v", err)
+			}
+			if !reflect.DeepEqual(m, tt.matrix) {
+				t.Errorf("expected %v, got %v", tt.matrix, m)
+			}
+			// A single point in time can be plotted
+			if _, err := PlotWithOptions(m, PlotOptions{Format: "png"}); err != nil {
+				t.Errorf("plot failed unexpectedly: %v", err)
+			}
+		})
+	}
+}
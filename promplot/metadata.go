@@ -0,0 +1,140 @@
+package promplot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Creator is set as the creating application in the metadata of PDF and EPS plots.
+const Creator = "promplot"
+
+// metadataPlot adds document metadata to formats which support it.
+// The canvases of gonum do not expose their metadata
+// so it is added to the encoded document instead.
+type metadataPlot struct {
+	io.WriterTo
+	format string
+	title  string
+}
+
+func withMetadata(plot io.WriterTo, format, title string) io.WriterTo {
+	if format != "pdf" && format != "eps" {
+		return plot
+	}
+	return metadataPlot{WriterTo: plot, format: format, title: title}
+}
+
+func (p metadataPlot) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	if _, err := p.WriterTo.WriteTo(&buf); err != nil {
+		return 0, err
+	}
+	doc := buf.Bytes()
+	switch p.format {
+	case "pdf":
+		doc = pdfMetadata(doc, p.title, time.Now())
+	case "eps":
+		doc = epsMetadata(doc, p.title)
+	}
+	n, err := w.Write(doc)
+	return int64(n), err
+}
+
+var (
+	pdfStartXRef = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+	pdfSize      = regexp.MustCompile(`/Size\s+(\d+)`)
+	pdfRoot      = regexp.MustCompile(`/Root\s+\d+\s+\d+\s+R`)
+)
+
+// pdfMetadata appends an incremental update to a PDF document
+// which replaces its document information with title and creator.
+// The document is returned unchanged if its trailer cannot be parsed.
+func pdfMetadata(doc []byte, title string, now time.Time) []byte {
+	xref := pdfStartXRef.FindSubmatch(doc)
+	if xref == nil {
+		return doc
+	}
+	trailerStart := bytes.LastIndex(doc, []byte("trailer"))
+	if trailerStart < 0 {
+		return doc
+	}
+	trailer := doc[trailerStart:]
+	sizeMatch := pdfSize.FindSubmatch(trailer)
+	root := pdfRoot.Find(trailer)
+	if sizeMatch == nil || root == nil {
+		return doc
+	}
+	size, err := strconv.Atoi(string(sizeMatch[1]))
+	if err != nil {
+		return doc
+	}
+
+	var b bytes.Buffer
+	b.Write(doc)
+	if !bytes.HasSuffix(doc, []byte("\n")) {
+		b.WriteString("\n")
+	}
+	offset := b.Len()
+	fmt.Fprintf(&b, "%d 0 obj\n<<\n", size)
+	if title != "" {
+		fmt.Fprintf(&b, "/Title %s\n", pdfString(title))
+	}
+	fmt.Fprintf(&b, "/Creator %s\n", pdfString(Creator))
+	fmt.Fprintf(&b, "/CreationDate (D:%s)\n", now.UTC().Format("20060102150405Z"))
+	b.WriteString(">>\nendobj\n")
+	xrefOffset := b.Len()
+	fmt.Fprintf(&b, "xref\n%d 1\n%010d 00000 n \n", size, offset)
+	fmt.Fprintf(&b, "trailer\n<<\n/Size %d\n%s\n/Info %d 0 R\n/Prev %s\n>>\n", size+1, root, size, xref[1])
+	fmt.Fprintf(&b, "startxref\n%d\n%%%%EOF\n", xrefOffset)
+	return b.Bytes()
+}
+
+// pdfString encodes s as a PDF string literal.
+// Non-ASCII text is encoded as UTF-16 with byte order mark.
+func pdfString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r > 126 || r < 32 {
+			ascii = false
+			break
+		}
+	}
+	if !ascii {
+		var b strings.Builder
+		b.WriteString("\xfe\xff")
+		for _, c := range utf16.Encode([]rune(s)) {
+			b.WriteByte(byte(c >> 8))
+			b.WriteByte(byte(c))
+		}
+		s = b.String()
+	}
+	return "(" + strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`, "\r", `\r`).Replace(s) + ")"
+}
+
+// epsMetadata replaces the title and creator comments in the header of an EPS document.
+func epsMetadata(doc []byte, title string) []byte {
+	end := bytes.Index(doc, []byte("%%EndComments"))
+	if end < 0 {
+		return doc
+	}
+	// Comments must fit on a single line
+	title = strings.Join(strings.Fields(title), " ")
+	var b bytes.Buffer
+	for _, line := range strings.SplitAfter(string(doc[:end]), "\n") {
+		switch {
+		case strings.HasPrefix(line, "%%Creator"):
+			line = "%%Creator: " + Creator + "\n"
+		case strings.HasPrefix(line, "%%Title"):
+			line = "%%Title: " + title + "\n"
+		}
+		b.WriteString(line)
+	}
+	b.Write(doc[end:])
+	return b.Bytes()
+}
@@ -0,0 +1,82 @@
+package promplot
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPDFMetadata(t *testing.T) {
+	doc := "%PDF-1.3\n1 0 obj\n<<\n/Type /Catalog\n>>\nendobj\n2 0 obj\n<<\n/Producer (test)\n>>\nendobj\n" +
+		"xref\n0 3\n0000000000 65535 f \n0000000009 00000 n \n0000000052 00000 n \n" +
+		"trailer\n<<\n/Size 3\n/Root 1 0 R\n/Info 2 0 R\n>>\nstartxref\n93\n%%EOF\n"
+	now := time.Date(2017, 7, 14, 2, 40, 0, 0, time.UTC)
+
+	out := string(pdfMetadata([]byte(doc), "Load (5m)", now))
+	if !strings.HasPrefix(out, doc) {
+		t.Fatal("original document should be kept")
+	}
+	info := len(doc)
+	expected := "3 0 obj\n<<\n/Title (Load \\(5m\\))\n/Creator (promplot)\n/CreationDate (D:20170714024000Z)\n>>\nendobj\n"
+	xref := info + len(expected)
+	expected += fmt.Sprintf("xref\n3 1\n%010d 00000 n \n", info) +
+		"trailer\n<<\n/Size 4\n/Root 1 0 R\n/Info 3 0 R\n/Prev 93\n>>\n" +
+		fmt.Sprintf("startxref\n%d\n%%%%EOF\n", xref)
+	if out[len(doc):] != expected {
+		t.Errorf(`
+Expected: %q
+Got       %q`, expected, out[len(doc):])
+	}
+	if !strings.HasPrefix(out[xref:], "xref") {
+		t.Errorf("startxref does not point to the cross-reference section")
+	}
+
+	if out := pdfMetadata([]byte("not a pdf"), "title", now); string(out) != "not a pdf" {
+		t.Errorf("invalid documents should not be changed, got: %q", out)
+	}
+}
+
+func TestPDFString(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{in: "", out: "()"},
+		{in: "Load", out: "(Load)"},
+		{in: `a\b (c)`, out: `(a\\b \(c\))`},
+		{in: "ä", out: "(\xfe\xff\x00\xe4)"},
+	}
+	for _, tt := range tests {
+		if out := pdfString(tt.in); out != tt.out {
+			t.Errorf("%q: expected %q, got %q", tt.in, tt.out, out)
+		}
+	}
+}
+
+func TestEPSMetadata(t *testing.T) {
+	doc := "%!PS-Adobe-3.0 EPSF-3.0\n%%Creator gonum.org/v1/plot/vg/vgeps\n%%Title: \n%%EndComments\n\n%%Title: body\n"
+	expected := "%!PS-Adobe-3.0 EPSF-3.0\n%%Creator: promplot\n%%Title: Two lines\n%%EndComments\n\n%%Title: body\n"
+	if out := string(epsMetadata([]byte(doc), "Two\nlines")); out != expected {
+		t.Errorf(`
+Expected: %q
+Got       %q`, expected, out)
+	}
+}
+
+func TestPlotMetadata(t *testing.T) {
+	for _, format := range []string{"pdf", "eps"} {
+		plot, err := PlotWithOptions(testMatrix(2), PlotOptions{Title: "Metadata", Format: format})
+		if err != nil {
+			t.Fatalf("%s: plotting failed unexpectedly: %v", format, err)
+		}
+		var buf bytes.Buffer
+		if _, err := plot.WriteTo(&buf); err != nil {
+			t.Fatalf("%s: writing failed unexpectedly: %v", format, err)
+		}
+		if !bytes.Contains(buf.Bytes(), []byte("promplot")) || !bytes.Contains(buf.Bytes(), []byte("Metadata")) {
+			t.Errorf("%s: missing metadata", format)
+		}
+	}
+}
@@ -2,36 +2,348 @@ package promplot
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 )
 
+// MetricsConfig holds optional settings for connecting to Prometheus.
+type MetricsConfig struct {
+	// Username and Password are used for HTTP basic auth.
+	Username string
+	Password string
+	// BearerToken is sent in the Authorization header.
+	// It cannot be combined with basic auth.
+	BearerToken string
+	// Step between data points. Overrides the step derived from the number of points.
+	Step time.Duration
+	// MinStep is the smallest step used, e.g. the scrape interval to avoid points without new data.
+	// Defaults to the package constant MinStep.
+	MinStep time.Duration
+	// TLSConfig is used for HTTPS connections, e.g. to trust a custom CA.
+	TLSConfig *tls.Config
+	// RoundTripper replaces the default transport. It cannot be combined with TLSConfig and Proxy.
+	// Authentication is still added on top of it.
+	RoundTripper http.RoundTripper
+	// Proxy is used for all requests instead of the proxy from HTTP_PROXY and HTTPS_PROXY, see ParseProxy.
+	Proxy *url.URL
+	// MaxRedirects limits the number of redirects which are followed.
+	// Zero keeps the default of net/http which follows up to 10 redirects, negative values follow none.
+	// Note that net/http drops auth headers when redirected to another host.
+	MaxRedirects int
+	// Headers are added to every request, e.g. for auth proxies.
+	Headers http.Header
+	// Tenant is sent in the TenantHeader for multi-tenant setups like Grafana Mimir or Cortex.
+	// It cannot be combined with the same header in Headers.
+	Tenant string
+	// AlignStep rounds start and end of range queries down to multiples of the step like Grafana does.
+	// This keeps the data points of consecutive queries at the same times.
+	AlignStep bool
+	// Logf is called with the URL, status and duration of every request to Prometheus. Optional.
+	// Headers and passwords in the URL are never logged.
+	Logf func(format string, a ...interface{})
+}
+
+// TenantHeader selects the tenant in Grafana Mimir and Cortex.
+const TenantHeader = "X-Scope-OrgID"
+
+// Limits for the resolution of range queries accepted by Prometheus
+const (
+	MinStep   = time.Second
+	MaxPoints = 11000
+)
+
+// QueryStep calculates the step between data points for a range query over duration.
+// If step is zero, it is derived by dividing duration into the given number of points.
+// The result is at least MinStep and large enough for the query to return at most MaxPoints points.
+func QueryStep(duration time.Duration, points int, step time.Duration) time.Duration {
+	return QueryStepWithMin(duration, points, step, MinStep)
+}
+
+// QueryStepWithMin calculates the step like QueryStep but with a result of at least minStep.
+// Values of minStep below MinStep are ignored.
+func QueryStepWithMin(duration time.Duration, points int, step, minStep time.Duration) time.Duration {
+	if step == 0 && points > 0 {
+		step = duration / time.Duration(points)
+	}
+	if minStep < MinStep {
+		minStep = MinStep
+	}
+	if step < minStep {
+		step = minStep
+	}
+	if duration/step >= MaxPoints {
+		// Round up to whole seconds to stay below the limit
+		step = (duration/(MaxPoints-1) + time.Second - 1).Truncate(time.Second)
+	}
+	return step
+}
+
+// AlignTime rounds t down to a multiple of step since the Unix epoch.
+// Unlike time.Truncate this matches the alignment of Prometheus and Grafana.
+func AlignTime(t time.Time, step time.Duration) time.Time {
+	if step <= 0 {
+		return t
+	}
+	ns := t.UnixNano()
+	rem := ns % int64(step)
+	if rem < 0 {
+		rem += int64(step)
+	}
+	return time.Unix(0, ns-rem).In(t.Location())
+}
+
+// roundTripper creates the transport used for requests to Prometheus.
+func (c MetricsConfig) roundTripper() (http.RoundTripper, error) {
+	rt := api.DefaultRoundTripper
+	switch {
+	case c.RoundTripper != nil && c.TLSConfig != nil:
+		return nil, fmt.Errorf("custom round tripper and TLS config cannot be used at the same time")
+	case c.RoundTripper != nil && c.Proxy != nil:
+		return nil, fmt.Errorf("custom round tripper and proxy cannot be used at the same time")
+	case c.RoundTripper != nil:
+		rt = c.RoundTripper
+	case c.TLSConfig != nil || c.Proxy != nil:
+		t, ok := rt.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("default round tripper does not support TLS config and proxy")
+		}
+		t = t.Clone()
+		if c.TLSConfig != nil {
+			t.TLSClientConfig = c.TLSConfig
+		}
+		if c.Proxy != nil {
+			t.Proxy = http.ProxyURL(c.Proxy)
+		}
+		rt = t
+	}
+	basicAuth := c.Username != "" || c.Password != ""
+	if basicAuth && c.BearerToken != "" {
+		return nil, fmt.Errorf("basic auth and bearer token cannot be used at the same time")
+	}
+	if basicAuth {
+		rt = config.NewBasicAuthRoundTripper(c.Username, config.Secret(c.Password), "", rt)
+	}
+	if c.BearerToken != "" {
+		rt = config.NewBearerAuthRoundTripper(config.Secret(c.BearerToken), rt)
+	}
+	headers := c.Headers
+	if c.Tenant != "" {
+		if headers.Get(TenantHeader) != "" {
+			return nil, fmt.Errorf("tenant and %s header cannot be used at the same time", TenantHeader)
+		}
+		headers = headers.Clone()
+		if headers == nil {
+			headers = http.Header{}
+		}
+		headers.Set(TenantHeader, c.Tenant)
+	}
+	if len(headers) > 0 {
+		rt = headerRoundTripper{headers: headers, rt: rt}
+	}
+	if c.Logf != nil {
+		rt = logRoundTripper{logf: c.Logf, rt: rt}
+	}
+	return rt, nil
+}
+
+// headerRoundTripper adds headers to all requests.
+type headerRoundTripper struct {
+	headers http.Header
+	rt      http.RoundTripper
+}
+
+func (h headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the original request
+	req = req.Clone(req.Context())
+	for name, values := range h.headers {
+		req.Header.Del(name)
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	return h.rt.RoundTrip(req)
+}
+
+// logRoundTripper logs all requests.
+type logRoundTripper struct {
+	logf func(format string, a ...interface{})
+	rt   http.RoundTripper
+}
+
+func (l logRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Queries are sent as form data, log them as part of the URL to make them reproducible
+	u := *req.URL
+	if req.GetBody != nil && req.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+		if body, err := req.GetBody(); err == nil {
+			if form, err := ioutil.ReadAll(body); err == nil {
+				u.RawQuery = string(form)
+			}
+			body.Close()
+		}
+	}
+	start := time.Now()
+	resp, err := l.rt.RoundTrip(req)
+	took := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		l.logf("%s %s failed after %v: %v", req.Method, u.Redacted(), took, err)
+		return nil, err
+	}
+	l.logf("%s %s: %s in %v", req.Method, u.Redacted(), resp.Status, took)
+	return resp, nil
+}
+
 // Metrics fetches data from Prometheus.
-func Metrics(server, query string, queryTime time.Time, duration, step time.Duration) (model.Matrix, error) {
-	client, err := api.NewClient(api.Config{Address: server})
+// The data is split into step points, see QueryStep.
+// The request is aborted when ctx is canceled or its deadline is exceeded.
+// Errors wrap ErrQuery, or ErrEmptyResult if the query matches no data.
+func Metrics(ctx context.Context, server, query string, queryTime time.Time, duration, step time.Duration) (model.Matrix, error) {
+	return MetricsWithConfig(ctx, server, query, queryTime, duration, step, MetricsConfig{})
+}
+
+// MetricsWithConfig fetches data from Prometheus using the given connection settings.
+func MetricsWithConfig(ctx context.Context, server, query string, queryTime time.Time, duration, step time.Duration, cfg MetricsConfig) (metrics model.Matrix, err error) {
+	defer wrap(ErrQuery, &err)
+	promAPI, err := newAPI(server, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create prometheus api client: %v", err)
+		return nil, err
 	}
+	metrics, err = queryRange(ctx, promAPI, query, queryTime, duration, QueryStepWithMin(duration, int(step), cfg.Step, cfg.MinStep), cfg.AlignStep)
+	if err != nil {
+		return nil, err
+	}
+	return metrics, CheckEmpty(metrics)
+}
 
-	promAPI := v1.NewAPI(client)
+// MetricsQueries runs multiple queries against Prometheus and merges all results into a single matrix.
+// Series of different queries are kept separately, even if they have the same labels.
+// ErrEmptyResult is only returned if none of the queries matches any data.
+func MetricsQueries(ctx context.Context, server string, queries []string, queryTime time.Time, duration, step time.Duration, cfg MetricsConfig) (metrics model.Matrix, err error) {
+	defer wrap(ErrQuery, &err)
+	promAPI, err := newAPI(server, cfg)
+	if err != nil {
+		return nil, err
+	}
+	metrics = model.Matrix{}
+	for _, query := range queries {
+		m, err := queryRange(ctx, promAPI, query, queryTime, duration, QueryStepWithMin(duration, int(step), cfg.Step, cfg.MinStep), cfg.AlignStep)
+		if err != nil {
+			return nil, fmt.Errorf("query %q: %w", query, err)
+		}
+		metrics = append(metrics, m...)
+	}
+	return metrics, CheckEmpty(metrics)
+}
 
-	value, _, err := promAPI.QueryRange(context.Background(), query, v1.Range{
-		Start: queryTime.Add(-duration),
-		End:   queryTime,
-		Step:  duration / step,
-	})
+// CheckQuery runs query as instant query at queryTime to check that Prometheus is reachable and the query is valid.
+// Queries without data pass the check.
+// Errors wrap ErrQuery.
+func CheckQuery(ctx context.Context, server, query string, queryTime time.Time, cfg MetricsConfig) (err error) {
+	defer wrap(ErrQuery, &err)
+	promAPI, err := newAPI(server, cfg)
+	if err != nil {
+		return err
+	}
+	if _, _, err := promAPI.Query(ctx, query, queryTime); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("prometheus query exceeded the deadline: %w", err)
+		}
+		return fmt.Errorf("failed to query prometheus api: %w", err)
+	}
+	return nil
+}
+
+// CheckEmpty returns ErrEmptyResult if metrics contains no samples.
+func CheckEmpty(metrics model.Matrix) error {
+	for _, sample := range metrics {
+		if len(sample.Values) > 0 {
+			return nil
+		}
+	}
+	return ErrEmptyResult
+}
+
+func newAPI(server string, cfg MetricsConfig) (v1.API, error) {
+	client, err := newClient(server, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return v1.NewAPI(client), nil
+}
+
+func newClient(server string, cfg MetricsConfig) (api.Client, error) {
+	rt, err := cfg.roundTripper()
 	if err != nil {
-		return nil, fmt.Errorf("failed to query prometheus api: %v", err)
+		return nil, err
 	}
 
-	metrics, ok := value.(model.Matrix)
-	if !ok {
-		return nil, fmt.Errorf("unsupported result format: %s", value.Type().String())
+	client, err := api.NewClient(api.Config{Address: server, RoundTripper: rt})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prometheus api client: %w", err)
+	}
+	if cfg.MaxRedirects != 0 {
+		// The client of the API does not allow configuring redirects
+		client = redirectClient{Client: client, client: &http.Client{Transport: rt, CheckRedirect: cfg.checkRedirect()}}
+	}
+	return client, nil
+}
+
+// checkRedirect returns the redirect policy for requests to Prometheus, nil for the default.
+func (c MetricsConfig) checkRedirect() func(*http.Request, []*http.Request) error {
+	if c.MaxRedirects == 0 {
+		return nil
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if c.MaxRedirects < 0 {
+			return fmt.Errorf("not following redirect to %s", req.URL.Redacted())
+		}
+		if len(via) > c.MaxRedirects {
+			return fmt.Errorf("stopped after %d redirects at %s", c.MaxRedirects, req.URL.Redacted())
+		}
+		return nil
+	}
+}
+
+// redirectClient is an API client sending requests with its own HTTP client.
+type redirectClient struct {
+	api.Client
+	client *http.Client
+}
+
+func (c redirectClient) Do(ctx context.Context, req *http.Request) (*http.Response, []byte, error) {
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return resp, body, err
+}
+
+func queryRange(ctx context.Context, promAPI v1.API, query string, queryTime time.Time, duration, step time.Duration, align bool) (model.Matrix, error) {
+	r := v1.Range{
+		Start: queryTime.Add(-duration),
+		End:   queryTime,
+		Step:  step,
+	}
+	if align {
+		r.Start, r.End = AlignTime(r.Start, step), AlignTime(r.End, step)
+	}
+	value, _, err := promAPI.QueryRange(ctx, query, r)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("prometheus query exceeded the deadline: %w", err)
+		}
+		return nil, fmt.Errorf("failed to query prometheus api: %w", err)
 	}
 
-	return metrics, nil
+	return ToMatrix(value)
 }
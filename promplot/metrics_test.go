@@ -0,0 +1,438 @@
+package promplot
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+const matrixResponse = `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"node"},"values":[[1500000000,"1"],[1500000060,"2"]]}]}}`
+
+func TestMetricsAuth(t *testing.T) {
+	tests := []struct {
+		cfg     MetricsConfig
+		header  string
+		invalid bool
+	}{
+		{cfg: MetricsConfig{}, header: ""},
+		{cfg: MetricsConfig{Username: "user", Password: "pass"}, header: "Basic dXNlcjpwYXNz"},
+		{cfg: MetricsConfig{BearerToken: "token"}, header: "Bearer token"},
+		{cfg: MetricsConfig{Username: "user", BearerToken: "token"}, invalid: true},
+	}
+
+	for i, tt := range tests {
+		var header string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header.Get("Authorization")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(matrixResponse))
+		}))
+
+		_, err := MetricsWithConfig(context.Background(), srv.URL, "up", time.Unix(1500000060, 0), time.Minute, 1, tt.cfg)
+		srv.Close()
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("%d. query failed unexpectedly: %v", i, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("%d. query should have failed", i)
+			continue
+		}
+		if header != tt.header {
+			t.Errorf(`
+%d.
+Expected: %q
+Got       %q`, i, tt.header, header)
+		}
+	}
+}
+
+func TestMetricsTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := Metrics(ctx, srv.URL, "up", time.Unix(1500000060, 0), time.Minute, 1)
+	if err == nil {
+		t.Fatal("query should have failed")
+	}
+	if !strings.Contains(err.Error(), "exceeded the deadline") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMetricsQueries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.FormValue("query") == "broken" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+			return
+		}
+		w.Write([]byte(matrixResponse))
+	}))
+	defer srv.Close()
+
+	queryTime := time.Unix(1500000060, 0)
+	m, err := MetricsQueries(context.Background(), srv.URL, []string{"up", "up"}, queryTime, time.Minute, 1, MetricsConfig{})
+	if err != nil {
+		t.Fatalf("query failed unexpectedly: %v", err)
+	}
+	if len(m) != 2 {
+		t.Errorf("expected 2 series, got %d", len(m))
+	}
+
+	_, err = MetricsQueries(context.Background(), srv.URL, []string{"up", "broken"}, queryTime, time.Minute, 1, MetricsConfig{})
+	if err == nil {
+		t.Fatal("query should have failed")
+	}
+	if !strings.Contains(err.Error(), `"broken"`) {
+		t.Errorf("error should name failed query: %v", err)
+	}
+}
+
+func TestMetricsTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(matrixResponse))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		cfg     MetricsConfig
+		invalid bool
+	}{
+		{cfg: MetricsConfig{}, invalid: true},
+		{cfg: MetricsConfig{TLSConfig: &tls.Config{InsecureSkipVerify: true}}},
+		{cfg: MetricsConfig{RoundTripper: srv.Client().Transport}},
+		{cfg: MetricsConfig{RoundTripper: srv.Client().Transport, BearerToken: "token"}},
+		{cfg: MetricsConfig{RoundTripper: srv.Client().Transport, TLSConfig: &tls.Config{}}, invalid: true},
+	}
+
+	for i, tt := range tests {
+		_, err := MetricsWithConfig(context.Background(), srv.URL, "up", time.Unix(1500000060, 0), time.Minute, 1, tt.cfg)
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("%d. query failed unexpectedly: %v", i, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("%d. query should have failed", i)
+		}
+	}
+}
+
+func TestMetricsHeaders(t *testing.T) {
+	var headers http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(matrixResponse))
+	}))
+	defer srv.Close()
+
+	cfg := MetricsConfig{
+		BearerToken: "token",
+		Headers:     http.Header{"X-Scope-Orgid": {"tenant"}, "X-Multi": {"a", "b"}},
+	}
+	if _, err := MetricsWithConfig(context.Background(), srv.URL, "up", time.Unix(1500000060, 0), time.Minute, 1, cfg); err != nil {
+		t.Fatalf("query failed unexpectedly: %v", err)
+	}
+	if h := headers.Get("X-Scope-OrgID"); h != "tenant" {
+		t.Errorf("expected tenant header, got %q", h)
+	}
+	if h := headers["X-Multi"]; len(h) != 2 || h[0] != "a" || h[1] != "b" {
+		t.Errorf("expected multiple header values, got %q", h)
+	}
+	if h := headers.Get("Authorization"); h != "Bearer token" {
+		t.Errorf("expected authorization header to be kept, got %q", h)
+	}
+}
+
+func TestMetricsRedirects(t *testing.T) {
+	// Redirects twice before answering, keeping the method and form of the query
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/query_range":
+			http.Redirect(w, r, "/first/api/v1/query_range", http.StatusTemporaryRedirect)
+		case "/first/api/v1/query_range":
+			http.Redirect(w, r, "/second/api/v1/query_range", http.StatusTemporaryRedirect)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(matrixResponse))
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		max int
+		err string
+	}{
+		{max: 0},
+		{max: 2},
+		{max: 1, err: "stopped after 1 redirects at " + srv.URL + "/second/api/v1/query_range"},
+		{max: -1, err: "not following redirect to " + srv.URL + "/first/api/v1/query_range"},
+	}
+
+	for i, tt := range tests {
+		_, err := MetricsWithConfig(context.Background(), srv.URL, "up", time.Unix(1500000060, 0), time.Minute, 1, MetricsConfig{MaxRedirects: tt.max})
+		if tt.err == "" && err != nil {
+			t.Errorf("%d. query failed unexpectedly: %v", i, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%d. expected error containing %q, got: %v", i, tt.err, err)
+		}
+	}
+}
+
+func TestMetricsTenant(t *testing.T) {
+	tests := []struct {
+		cfg     MetricsConfig
+		tenant  string
+		invalid bool
+	}{
+		{cfg: MetricsConfig{Tenant: "team"}, tenant: "team"},
+		{cfg: MetricsConfig{Tenant: "team", Headers: http.Header{"X-Other": {"value"}}}, tenant: "team"},
+		{cfg: MetricsConfig{Headers: http.Header{"X-Scope-Orgid": {"other"}}}, tenant: "other"},
+		{cfg: MetricsConfig{Tenant: "team", Headers: http.Header{"X-Scope-Orgid": {"other"}}}, invalid: true},
+	}
+
+	for i, tt := range tests {
+		var tenant string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant = r.Header.Get(TenantHeader)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(matrixResponse))
+		}))
+
+		_, err := MetricsWithConfig(context.Background(), srv.URL, "up", time.Unix(1500000060, 0), time.Minute, 1, tt.cfg)
+		srv.Close()
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("%d. query failed unexpectedly: %v", i, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("%d. query should have failed", i)
+			continue
+		}
+		if tenant != tt.tenant {
+			t.Errorf("%d. expected tenant %q, got %q", i, tt.tenant, tenant)
+		}
+	}
+}
+
+func TestMetricsLogf(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(matrixResponse))
+	}))
+	defer srv.Close()
+
+	var logs []string
+	cfg := MetricsConfig{
+		Username: "user",
+		Password: "secret",
+		Logf: func(format string, a ...interface{}) {
+			logs = append(logs, fmt.Sprintf(format, a...))
+		},
+	}
+	server := strings.Replace(srv.URL, "://", "://admin:hunter2@", 1)
+	if _, err := MetricsWithConfig(context.Background(), server, "up", time.Unix(1500000060, 0), time.Minute, 1, cfg); err != nil {
+		t.Fatalf("query failed unexpectedly: %v", err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("expected 1 log line, got %q", logs)
+	}
+	if !strings.Contains(logs[0], "/api/v1/query_range?") || !strings.Contains(logs[0], "query=up") || !strings.Contains(logs[0], "200 OK") {
+		t.Errorf("log should contain query URL and status: %s", logs[0])
+	}
+	if strings.Contains(logs[0], "secret") || strings.Contains(logs[0], "hunter2") {
+		t.Errorf("log should not contain passwords: %s", logs[0])
+	}
+}
+
+func TestQueryStep(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		points   int
+		step     time.Duration
+		expected time.Duration
+	}{
+		{duration: 100 * time.Minute, points: 100, expected: time.Minute},
+		{duration: time.Minute, points: 100, expected: time.Second},
+		{duration: time.Hour, points: 100, step: 10 * time.Second, expected: 10 * time.Second},
+		{duration: time.Hour, points: 100, step: time.Millisecond, expected: time.Second},
+		{duration: 7 * 24 * time.Hour, step: time.Second, expected: 55 * time.Second},
+		{duration: 10999 * time.Second, step: time.Second, expected: time.Second},
+		{duration: 11000 * time.Second, step: time.Second, expected: 2 * time.Second},
+	}
+
+	for i, tt := range tests {
+		step := QueryStep(tt.duration, tt.points, tt.step)
+		if step != tt.expected {
+			t.Errorf(`
+%d.
+Input:    %v / %d, %v
+Expected: %v
+Got       %v`, i, tt.duration, tt.points, tt.step, tt.expected, step)
+		}
+		if tt.duration/step >= MaxPoints {
+			t.Errorf("%d. step %v exceeds maximum resolution", i, step)
+		}
+	}
+}
+
+func TestQueryStepWithMin(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		points   int
+		step     time.Duration
+		minStep  time.Duration
+		expected time.Duration
+	}{
+		{duration: time.Hour, points: 100, minStep: time.Minute, expected: time.Minute},
+		{duration: 10 * time.Hour, points: 100, minStep: time.Minute, expected: 6 * time.Minute},
+		{duration: time.Hour, points: 100, step: 10 * time.Second, minStep: 30 * time.Second, expected: 30 * time.Second},
+		// Too small minimums fall back to the minimum of Prometheus
+		{duration: time.Minute, points: 100, minStep: time.Millisecond, expected: time.Second},
+		{duration: time.Minute, points: 100, expected: time.Second},
+		// The resolution limit wins over the minimum
+		{duration: 30 * 24 * time.Hour, step: time.Second, minStep: 15 * time.Second, expected: 236 * time.Second},
+		{duration: 365 * 24 * time.Hour, points: 100000, minStep: time.Minute, expected: 2868 * time.Second},
+	}
+
+	for i, tt := range tests {
+		step := QueryStepWithMin(tt.duration, tt.points, tt.step, tt.minStep)
+		if step != tt.expected {
+			t.Errorf(`
+%d.
+Input:    %v / %d, %v, min %v
+Expected: %v
+Got       %v`, i, tt.duration, tt.points, tt.step, tt.minStep, tt.expected, step)
+		}
+		if tt.duration/step >= MaxPoints {
+			t.Errorf("%d. step %v exceeds maximum resolution", i, step)
+		}
+	}
+}
+
+func TestCheckQuery(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		err    string
+	}{
+		{status: http.StatusOK, body: `{"status":"success","data":{"resultType":"vector","result":[]}}`},
+		{status: http.StatusBadRequest, body: `{"status":"error","errorType":"bad_data","error":"parse error at char 4"}`, err: "parse error at char 4"},
+		{status: http.StatusServiceUnavailable, body: "unavailable", err: "503"},
+	}
+
+	for i, tt := range tests {
+		var path string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+		err := CheckQuery(context.Background(), srv.URL, "up{", time.Unix(1500000060, 0), MetricsConfig{})
+		srv.Close()
+		if path != "/api/v1/query" {
+			t.Errorf("%d. expected instant query, got %s", i, path)
+		}
+		if tt.err == "" && err != nil {
+			t.Errorf("%d. check failed unexpectedly: %v", i, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err) || !errors.Is(err, ErrQuery)) {
+			t.Errorf("%d. expected query error containing %q, got: %v", i, tt.err, err)
+		}
+	}
+}
+
+func TestCheckEmpty(t *testing.T) {
+	tests := []struct {
+		metrics model.Matrix
+		empty   bool
+	}{
+		{metrics: nil, empty: true},
+		{metrics: model.Matrix{}, empty: true},
+		{metrics: model.Matrix{{Metric: model.Metric{"job": "node"}}}, empty: true},
+		{metrics: model.Matrix{{}, {Values: []model.SamplePair{{Timestamp: 1, Value: 1}}}}},
+	}
+
+	for i, tt := range tests {
+		err := CheckEmpty(tt.metrics)
+		if tt.empty && !errors.Is(err, ErrEmptyResult) {
+			t.Errorf("%d. expected empty result, got: %v", i, err)
+		}
+		if !tt.empty && err != nil {
+			t.Errorf("%d. unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestAlignTime(t *testing.T) {
+	tests := []struct {
+		t        time.Time
+		step     time.Duration
+		expected time.Time
+	}{
+		{t: time.Unix(1500000059, 0), step: time.Minute, expected: time.Unix(1500000000, 0)},
+		{t: time.Unix(1500000000, 0), step: time.Minute, expected: time.Unix(1500000000, 0)},
+		{t: time.Unix(1500000010, 500), step: 36 * time.Second, expected: time.Unix(1499999976, 0)},
+		{t: time.Unix(-10, 0), step: time.Minute, expected: time.Unix(-60, 0)},
+		{t: time.Unix(1500000059, 0), step: 0, expected: time.Unix(1500000059, 0)},
+	}
+
+	for i, tt := range tests {
+		if aligned := AlignTime(tt.t, tt.step); !aligned.Equal(tt.expected) {
+			t.Errorf("%d. expected %v, got %v", i, tt.expected.Unix(), aligned.Unix())
+		}
+	}
+}
+
+func TestMetricsAlignStep(t *testing.T) {
+	var start, end string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, end = r.FormValue("start"), r.FormValue("end")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(matrixResponse))
+	}))
+	defer srv.Close()
+
+	queryTime := time.Unix(1500000090, 0)
+	if _, err := MetricsWithConfig(context.Background(), srv.URL, "up", queryTime, time.Hour, 0, MetricsConfig{Step: time.Minute, AlignStep: true}); err != nil {
+		t.Fatalf("query failed unexpectedly: %v", err)
+	}
+	if start != "1499996460" || end != "1500000060" {
+		t.Errorf("expected aligned range 1499996460 - 1500000060, got %s - %s", start, end)
+	}
+
+	if _, err := MetricsWithConfig(context.Background(), srv.URL, "up", queryTime, time.Hour, 0, MetricsConfig{Step: time.Minute}); err != nil {
+		t.Fatalf("query failed unexpectedly: %v", err)
+	}
+	if start != "1499996490" || end != "1500000090" {
+		t.Errorf("expected unaligned range 1499996490 - 1500000090, got %s - %s", start, end)
+	}
+}
@@ -0,0 +1,92 @@
+package promplot
+
+import (
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+	"gonum.org/v1/plot/vg"
+)
+
+// outlineCanvas draws text as filled glyph outlines instead of passing it on to the canvas.
+// The SVG canvas of gonum only references fonts by name, which viewers replace if the font is not installed.
+// The outlines use the same metrics as gonum when measuring text, so the layout stays the same.
+type outlineCanvas struct {
+	vg.CanvasWriterTo
+}
+
+func (c outlineCanvas) FillString(f vg.Font, pt vg.Point, text string) {
+	ttf := f.Font()
+	// Loading glyphs at a scale of one em per font unit keeps their coordinates in font units
+	em := fixed.Int26_6(ttf.FUnitsPerEm())
+	scale := f.Size / vg.Points(float64(ttf.FUnitsPerEm()))
+
+	var glyph truetype.GlyphBuf
+	var path vg.Path
+	x := pt.X
+	prev, hasPrev := truetype.Index(0), false
+	for _, r := range text {
+		index := ttf.Index(r)
+		if hasPrev {
+			x += vg.Length(ttf.Kern(em, prev, index)) * scale
+		}
+		// Glyphs which cannot be loaded are left out like missing glyphs in a viewer
+		if err := glyph.Load(ttf, em, index, font.HintingNone); err == nil {
+			start := 0
+			for _, end := range glyph.Ends {
+				appendContour(&path, glyph.Points[start:end], vg.Point{X: x, Y: pt.Y}, scale)
+				start = end
+			}
+		}
+		x += vg.Length(ttf.HMetric(em, index).AdvanceWidth) * scale
+		prev, hasPrev = index, true
+	}
+	if len(path) > 0 {
+		c.Fill(path)
+	}
+}
+
+// appendContour adds a closed TrueType contour to path, moved to origin and scaled from font units.
+// Contours consist of quadratic curves where two consecutive off-curve points imply an on-curve point in between.
+func appendContour(path *vg.Path, points []truetype.Point, origin vg.Point, scale vg.Length) {
+	if len(points) == 0 {
+		return
+	}
+	pos := func(p truetype.Point) vg.Point {
+		return vg.Point{X: origin.X + vg.Length(p.X)*scale, Y: origin.Y + vg.Length(p.Y)*scale}
+	}
+	onCurve := func(p truetype.Point) bool { return p.Flags&0x01 != 0 }
+	mid := func(a, b vg.Point) vg.Point { return vg.Point{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2} }
+
+	// Start at the first on-curve point and go around back to it.
+	// Without any, start between the last and the first point.
+	start := mid(pos(points[len(points)-1]), pos(points[0]))
+	rest := points
+	for i, p := range points {
+		if onCurve(p) {
+			start = pos(p)
+			rest = append(append([]truetype.Point{}, points[i+1:]...), points[:i+1]...)
+			break
+		}
+	}
+	path.Move(start)
+	var control *vg.Point
+	for _, p := range rest {
+		q := pos(p)
+		switch {
+		case onCurve(p) && control != nil:
+			path.QuadTo(*control, q)
+			control = nil
+		case onCurve(p):
+			path.Line(q)
+		case control != nil:
+			path.QuadTo(*control, mid(*control, q))
+			control = &q
+		default:
+			control = &q
+		}
+	}
+	if control != nil {
+		path.QuadTo(*control, start)
+	}
+	path.Close()
+}
@@ -0,0 +1,84 @@
+package promplot
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/math/fixed"
+	"gonum.org/v1/plot/vg"
+)
+
+func TestAppendContour(t *testing.T) {
+	// Glyph coordinates are in font units
+	on := func(x, y fixed.Int26_6) truetype.Point { return truetype.Point{X: x, Y: y, Flags: 1} }
+	off := func(x, y fixed.Int26_6) truetype.Point { return truetype.Point{X: x, Y: y} }
+	pt := func(x, y vg.Length) vg.Point { return vg.Point{X: x, Y: y} }
+
+	var square vg.Path
+	square.Move(pt(1, 2))
+	square.Line(pt(21, 2))
+	square.Line(pt(21, 22))
+	square.Line(pt(1, 22))
+	square.Line(pt(1, 2))
+	square.Close()
+
+	var circle vg.Path
+	circle.Move(pt(1.5, 1.5))
+	circle.QuadTo(pt(2, 2), pt(1.5, 2.5))
+	circle.QuadTo(pt(1, 3), pt(0.5, 2.5))
+	circle.QuadTo(pt(0, 2), pt(0.5, 1.5))
+	circle.QuadTo(pt(1, 1), pt(1.5, 1.5))
+	circle.Close()
+
+	var arch vg.Path
+	arch.Move(pt(1, 2))
+	arch.Line(pt(11, 2))
+	arch.QuadTo(pt(6, 12), pt(1, 2))
+	arch.Close()
+
+	tests := []struct {
+		points []truetype.Point
+		scale  vg.Length
+		path   vg.Path
+	}{
+		{points: []truetype.Point{on(0, 0), on(10, 0), on(10, 10), on(0, 10)}, scale: 2, path: square},
+		// Only off-curve points imply all on-curve points
+		{points: []truetype.Point{off(1, 0), off(0, 1), off(-1, 0), off(0, -1)}, scale: 1, path: circle},
+		{points: []truetype.Point{off(5, 10), on(0, 0), on(10, 0)}, scale: 1, path: arch},
+		{points: nil, scale: 1, path: nil},
+	}
+
+	for i, tt := range tests {
+		var path vg.Path
+		appendContour(&path, tt.points, pt(1, 2), tt.scale)
+		if !reflect.DeepEqual(path, tt.path) {
+			t.Errorf(`
+%d.
+Expected: %v
+Got:      %v`, i, tt.path, path)
+		}
+	}
+}
+
+func TestPlotEmbedFonts(t *testing.T) {
+	for _, embed := range []bool{false, true} {
+		plot, err := PlotWithOptions(testMatrix(2), PlotOptions{Format: "svg", Title: "Embedded", EmbedFonts: embed})
+		if err != nil {
+			t.Fatalf("plot failed unexpectedly: %v", err)
+		}
+		var buf bytes.Buffer
+		if _, err := plot.WriteTo(&buf); err != nil {
+			t.Fatalf("writing failed unexpectedly: %v", err)
+		}
+		svg := buf.String()
+		if hasText := strings.Contains(svg, "<text"); hasText == embed {
+			t.Errorf("embedding %v: unexpected text elements in SVG", embed)
+		}
+		if hasFont := strings.Contains(svg, "font-family"); hasFont == embed {
+			t.Errorf("embedding %v: unexpected font references in SVG", embed)
+		}
+	}
+}
@@ -1,10 +1,20 @@
 package promplot
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
 	"io"
+	"math"
 	"regexp"
-	"strconv"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/prometheus/common/model"
 	"gonum.org/v1/plot"
@@ -12,82 +22,1001 @@ import (
 	"gonum.org/v1/plot/plotter"
 	"gonum.org/v1/plot/vg"
 	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
 )
 
 // Only show important part of metric name
 var labelText = regexp.MustCompile("\\{(.*)\\}")
 
-// Plot creates a plot from metric data and saves it to a temporary file.
-// It's the callers responsibility to remove the returned file when no longer needed.
+// Default palette used for drawing lines
+const (
+	DefaultPalette     = "Dark2"
+	DefaultPaletteSize = 8
+)
+
+// DefaultTimeFormat is the layout of the X axis tick labels.
+const DefaultTimeFormat = "2006-01-02\n15:04"
+
+// Default dimensions of the canvas
+const (
+	DefaultWidth  = 24 * vg.Centimeter
+	DefaultHeight = 20 * vg.Centimeter
+	DefaultMargin = 6 * vg.Millimeter
+)
+
+// DefaultLineWidth is the width of the line of each series
+const DefaultLineWidth = vg.Length(1)
+
+// DashPatterns are cycled through per series when PlotOptions.Dash is set.
+// The lengths are scaled with the line width.
+var DashPatterns = [][]vg.Length{
+	nil,
+	{6, 3},
+	{2, 2},
+	{8, 3, 2, 3},
+	{1, 3},
+}
+
+// Default fonts, see Fonts for available names
+const (
+	DefaultFont          = "Helvetica"
+	DefaultTitleFontSize = vg.Centimeter
+	DefaultTickFontSize  = 3 * vg.Millimeter
+)
+
+// DefaultTitlePadding is the space between the title and the data area
+const DefaultTitlePadding = 2 * vg.Centimeter
+
+// PlotOptions configures how a plot is rendered.
+// Zero values are replaced with the defaults.
+type PlotOptions struct {
+	// Title of the graph. It is also stored in the metadata of PDF and EPS documents.
+	Title string
+	// HideTitle leaves the title out of the image so the data fills its space, e.g. for dashboards with their own header.
+	// The title is still stored in the metadata.
+	HideTitle bool
+	// Subtitle is drawn below the title in the style of the legend, e.g. to show the query.
+	// It is wrapped to the width of the plot and cut off after three lines.
+	Subtitle string
+	// Format of the image. For possible values see draw.NewFormattedCanvas.
+	Format string
+	// PNGCompression is the compression level of PNG images. One of PNGCompressions. Defaults to "default".
+	// Better compression results in smaller files but takes longer to encode.
+	PNGCompression string
+	// JPEGQuality is the quality of JPEG images from 1 to 100. Defaults to DefaultJPEGQuality.
+	// Lower quality creates smaller files with more artifacts.
+	JPEGQuality int
+	// Palette is the name of a Brewer color palette. Defaults to DefaultPalette.
+	Palette string
+	// PaletteSize is the number of colors to use from the palette. Defaults to DefaultPaletteSize.
+	PaletteSize int
+	// Width and Height of the canvas. Default to DefaultWidth and DefaultHeight.
+	Width  vg.Length
+	Height vg.Length
+	// Margin around the plot. Defaults to DefaultMargin.
+	Margin vg.Length
+	// Background fills the whole canvas including the margin.
+	// Defaults to white, except for the margin of vector formats like SVG which is transparent.
+	// Use color.Transparent for no background at all.
+	Background color.Color
+	// TextColor is the color of the title, tick labels, axis label and legend. Defaults to black.
+	TextColor color.Color
+	// AxisColor is the color of the axes and their ticks. Defaults to black.
+	AxisColor color.Color
+	// GridColor is the color of the grid lines. Defaults to gray.
+	GridColor color.Color
+	// Theme presets the colors and the palette. One of Themes. Defaults to "light".
+	// Colors and the palette set explicitly take precedence.
+	Theme string
+	// Font used for all text. One of Fonts. Defaults to DefaultFont.
+	// The title uses the bold variant of Courier, Helvetica and Times-Roman.
+	Font string
+	// EmbedFonts draws the text of SVG plots as glyph outlines instead of referring to the font by name,
+	// so that they look the same on systems without the font. The text can then not be selected.
+	// Other formats are not affected.
+	EmbedFonts bool
+	// TitleFontSize is the size of the title. Defaults to DefaultTitleFontSize.
+	TitleFontSize vg.Length
+	// TitleAlign is the horizontal alignment of the title and subtitle. One of TitleAligns. Defaults to "center".
+	TitleAlign string
+	// TitlePadding is the space between the title and the data area. Defaults to DefaultTitlePadding.
+	TitlePadding vg.Length
+	// TickFontSize is the size of tick labels, axis labels and the legend. Defaults to DefaultTickFontSize.
+	TickFontSize vg.Length
+	// Legend position. One of LegendPositions. Defaults to "top".
+	Legend string
+	// LegendFormat is a text/template executed with the labels of each series to create legend entries,
+	// for example "{{.instance}} ({{.job}})". Missing labels are rendered empty.
+	// Defaults to all labels of the series.
+	LegendFormat string
+	// LegendMax limits the legend to this many entries followed by an entry with the number of hidden ones.
+	// All series are still drawn. Defaults to no limit.
+	LegendMax int
+	// KeepLabels and DropLabels change the labels of each series before plotting, see Relabel.
+	// The legend and LegendFormat only see the remaining labels.
+	KeepLabels, DropLabels []string
+	// MergeSeries sums up series with the same labels after KeepLabels and DropLabels are applied.
+	MergeSeries bool
+	// YLabel is the label of the Y axis.
+	YLabel string
+	// YUnit formats the Y axis tick labels with a unit. One of Units.
+	// Defaults to plain numbers.
+	YUnit string
+	// XMin and XMax restrict the visible time range instead of showing all data. Optional.
+	XMin, XMax time.Time
+	// YMin and YMax fix the range of the Y axis instead of scaling it to the data. Optional.
+	// Data outside of the range is clipped.
+	YMin, YMax *float64
+	// LogY uses a logarithmic scale for the Y axis. All values must be positive, except for values below YMin which are not shown.
+	LogY bool
+	// SortBy sorts series descending by an aggregate of their values. One of Aggregates.
+	// This affects colors, legend order and drawing order. Defaults to sorting by labels.
+	SortBy string
+	// Grid draws grid lines at the axis ticks.
+	Grid bool
+	// TimeFormat is the time.Format layout of the X axis tick labels. Defaults to DefaultTimeFormat.
+	TimeFormat string
+	// Location is the time zone of the X axis tick labels. Defaults to UTC.
+	Location *time.Location
+	// Freshness draws a footer like "data as of 2024-01-02 15:04 UTC" with the time of the newest sample in Location,
+	// so that viewers can tell how old the data is. It is left out if there are no samples.
+	Freshness bool
+	// SmoothPoints applies a moving average over this many points to each series.
+	SmoothPoints int
+	// SmoothWindow applies a moving average over this time window to each series.
+	// Takes precedence over SmoothPoints.
+	SmoothWindow time.Duration
+	// SmoothOverlay draws the smoothed line on top of the raw data instead of replacing it.
+	SmoothOverlay bool
+	// Style of each series. One of Styles. Defaults to "line".
+	// Points are useful for sparse or irregular data where interpolating between samples is misleading.
+	Style string
+	// LineWidth is the width of the line of each series. Defaults to DefaultLineWidth.
+	LineWidth vg.Length
+	// Dash draws each series with a different dash pattern of DashPatterns in addition to its color,
+	// e.g. to tell series apart when printed in grayscale.
+	Dash bool
+	// GapThreshold is the maximum distance between two points which are still connected,
+	// e.g. to show missing samples during an outage as gaps.
+	// Defaults to three times the usual distance between the points of each series. Negative values connect all points.
+	GapThreshold time.Duration
+	// FillUnder shades the area between each line and the X axis.
+	// Works best with a single series since fills of multiple series overlap.
+	FillUnder bool
+	// HLines are horizontal reference lines drawn across the plot, e.g. thresholds.
+	HLines []HLine
+	// VLines are vertical marker lines drawn at points in time, e.g. deploys.
+	// Lines outside of the plotted time range are skipped and reported to Logf.
+	VLines []VLine
+	// Exemplars are drawn as rings on top of the series, e.g. to show traces of slow requests, see Exemplars.
+	Exemplars []Exemplar
+	// Heatmap draws the buckets of a histogram as heatmap instead of lines.
+	// Metrics must be the cumulative bucket series with the le label, e.g. of 'sum by (le) (rate(latency_bucket[5m]))'.
+	// Series of the same bucket are summed. The Y axis shows the upper bound of the buckets.
+	Heatmap bool
+	// Logf is called with warnings about parts of the plot which are left out, e.g. VLines outside of the plotted time range. Optional.
+	Logf func(format string, a ...interface{})
+}
+
+// TimeFormatFor returns a time format suitable for plotting the given time range.
+func TimeFormatFor(duration time.Duration) string {
+	switch {
+	case duration <= time.Hour:
+		return "15:04:05"
+	case duration <= 24*time.Hour:
+		return "15:04"
+	case duration <= 31*24*time.Hour:
+		return DefaultTimeFormat
+	default:
+		return "2006-01-02"
+	}
+}
+
+// ValidTimeFormat returns an error if layout does not contain any elements of the reference time.
+func ValidTimeFormat(layout string) error {
+	// Any time other than the reference time changes a valid layout
+	t := time.Date(2017, 11, 23, 8, 9, 10, 0, time.UTC)
+	formatted := t.Format(layout)
+	if formatted == layout {
+		return fmt.Errorf("time format %q does not contain any reference time elements like 2006, 01, 02, 15, 04 or 05", layout)
+	}
+	if _, err := time.Parse(layout, formatted); err != nil {
+		return fmt.Errorf("invalid time format %q: %w", layout, err)
+	}
+	return nil
+}
+
+// Formats are the image formats supported by Plot.
+var Formats = []string{"eps", "jpg", "jpeg", "pdf", "png", "svg", "tex", "tif", "tiff"}
+
+// ValidFormat returns an error if format is not one of Formats.
+func ValidFormat(format string) error {
+	for _, f := range Formats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported format %q, valid formats are: %s", format, strings.Join(Formats, ", "))
+}
+
+// ContentType returns the MIME type of a format, e.g. for HTTP responses.
+// Besides Formats, "csv" as written by CSV is supported.
+// Unknown formats result in "application/octet-stream".
+func ContentType(format string) string {
+	switch format {
+	case "eps":
+		return "application/postscript"
+	case "jpg", "jpeg":
+		return "image/jpeg"
+	case "pdf":
+		return "application/pdf"
+	case "png":
+		return "image/png"
+	case "svg":
+		return "image/svg+xml"
+	case "tex":
+		return "application/x-tex"
+	case "tif", "tiff":
+		return "image/tiff"
+	case "csv":
+		return "text/csv"
+	}
+	return "application/octet-stream"
+}
+
+// Styles are the valid values for PlotOptions.Style:
+// lines between data points, only the data points or both.
+var Styles = []string{"line", "points", "linepoints"}
+
+// DefaultJPEGQuality is the quality of JPEG images.
+// It is higher than the default of image/jpeg since its artifacts are clearly visible on lines and text.
+const DefaultJPEGQuality = 90
+
+// PNGCompressions are the valid values for PlotOptions.PNGCompression.
+var PNGCompressions = []string{"best-speed", "default", "best-compression"}
+
+// TitleAligns are the valid values for PlotOptions.TitleAlign.
+var TitleAligns = []string{"left", "center", "right"}
+
+// LegendPositions are the valid values for PlotOptions.Legend.
+var LegendPositions = []string{"top", "bottom", "left", "right", "none"}
+
+// Plot creates a plot from metric data using the default options.
 func Plot(metrics model.Matrix, title, format string) (io.WriterTo, error) {
+	return PlotWithOptions(metrics, PlotOptions{Title: title, Format: format})
+}
+
+// WritePlot creates a plot from metric data and writes it to w.
+// Plotting errors wrap ErrPlot.
+func WritePlot(metrics model.Matrix, title, format string, w io.Writer) error {
+	plot, err := Plot(metrics, title, format)
+	if err != nil {
+		return fmt.Errorf("failed to create plot: %w", err)
+	}
+	if _, err := plot.WriteTo(w); err != nil {
+		return fmt.Errorf("failed to write plot: %w", err)
+	}
+	return nil
+}
+
+// DataURI creates a plot from metric data and returns it as base64 encoded data URI,
+// e.g. for embedding it in HTML or JSON payloads.
+// Plotting errors wrap ErrPlot.
+func DataURI(metrics model.Matrix, title, format string) (string, error) {
+	plot, err := Plot(metrics, title, format)
+	if err != nil {
+		return "", fmt.Errorf("failed to create plot: %w", err)
+	}
+	var uri strings.Builder
+	uri.WriteString("data:" + ContentType(format) + ";base64,")
+	// Encode while writing to avoid another copy of the plot
+	enc := base64.NewEncoder(base64.StdEncoding, &uri)
+	if _, err := plot.WriteTo(enc); err != nil {
+		return "", fmt.Errorf("failed to write plot: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return "", fmt.Errorf("failed to encode plot: %w", err)
+	}
+	return uri.String(), nil
+}
+
+// PlotWithOptions creates a plot from metric data using the given options.
+// Errors wrap ErrPlot.
+func PlotWithOptions(metrics model.Matrix, opts PlotOptions) (_ io.WriterTo, err error) {
+	defer wrap(ErrPlot, &err)
+	opts, err = opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := newPlot(metrics, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Draw plot in canvas with margin
+	c, err := newCanvas(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create canvas: %w", err)
+	}
+	var canvas vg.CanvasSizer = c
+	if opts.EmbedFonts && opts.Format == "svg" {
+		canvas = outlineCanvas{c}
+	}
+	area := draw.Crop(draw.New(canvas), opts.Margin, -opts.Margin, opts.Margin, -opts.Margin)
+	if opts.Freshness {
+		area = drawFreshness(area, p, metrics, opts.Location)
+	}
+	drawPlot(area, p, opts)
+
+	if img, ok := c.(vgimg.PngCanvas); ok && opts.PNGCompression != "default" {
+		return pngPlot{img: img.Image(), level: pngCompressionLevel(opts.PNGCompression)}, nil
+	}
+	if img, ok := c.(vgimg.JpegCanvas); ok {
+		return jpegPlot{img: img.Image(), quality: opts.JPEGQuality}, nil
+	}
+	return withMetadata(c, opts.Format, opts.Title), nil
+}
+
+// withDefaults replaces zero values with defaults and validates the options.
+func (opts PlotOptions) withDefaults() (PlotOptions, error) {
+	if opts.Theme == "" {
+		opts.Theme = "light"
+	}
+	opts, err := applyTheme(opts)
+	if err != nil {
+		return opts, err
+	}
+	if opts.Palette == "" {
+		opts.Palette = DefaultPalette
+	}
+	if opts.PaletteSize == 0 {
+		opts.PaletteSize = DefaultPaletteSize
+	}
+	if opts.Width == 0 {
+		opts.Width = DefaultWidth
+	}
+	if opts.Height == 0 {
+		opts.Height = DefaultHeight
+	}
+	if opts.Margin == 0 {
+		opts.Margin = DefaultMargin
+	}
+	if opts.Legend == "" {
+		opts.Legend = "top"
+	}
+	if opts.Font == "" {
+		opts.Font = DefaultFont
+	}
+	if opts.TitleFontSize == 0 {
+		opts.TitleFontSize = DefaultTitleFontSize
+	}
+	if opts.TickFontSize == 0 {
+		opts.TickFontSize = DefaultTickFontSize
+	}
+	if opts.TitleAlign == "" {
+		opts.TitleAlign = "center"
+	}
+	if opts.TitlePadding == 0 {
+		opts.TitlePadding = DefaultTitlePadding
+	}
+	if opts.Style == "" {
+		opts.Style = "line"
+	}
+	if opts.PNGCompression == "" {
+		opts.PNGCompression = "default"
+	}
+	if opts.JPEGQuality == 0 {
+		opts.JPEGQuality = DefaultJPEGQuality
+	}
+	if opts.LineWidth == 0 {
+		opts.LineWidth = DefaultLineWidth
+	}
+	if opts.TimeFormat == "" {
+		opts.TimeFormat = DefaultTimeFormat
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if err := ValidTimeFormat(opts.TimeFormat); err != nil {
+		return opts, err
+	}
+	if err := ValidFormat(opts.Format); err != nil {
+		return opts, err
+	}
+	if err := validFontFormat(opts.Font, opts.Format, opts.EmbedFonts); err != nil {
+		return opts, err
+	}
+	if opts.YUnit != "" {
+		if err := validUnit(opts.YUnit); err != nil {
+			return opts, err
+		}
+	}
+	if !opts.XMin.IsZero() && !opts.XMax.IsZero() && !opts.XMin.Before(opts.XMax) {
+		return opts, fmt.Errorf("X axis start %s must be before end %s", opts.XMin.Format(time.RFC3339), opts.XMax.Format(time.RFC3339))
+	}
+	if opts.YMin != nil && opts.YMax != nil && *opts.YMin >= *opts.YMax {
+		return opts, fmt.Errorf("Y axis minimum %v must be smaller than maximum %v", *opts.YMin, *opts.YMax)
+	}
+	if opts.SortBy != "" {
+		if err := validAggregate(opts.SortBy); err != nil {
+			return opts, err
+		}
+	}
+	if opts.TitleFontSize < 0 || opts.TickFontSize < 0 {
+		return opts, fmt.Errorf("font sizes must be positive")
+	}
+	if err := validTitleAlign(opts.TitleAlign); err != nil {
+		return opts, err
+	}
+	if opts.Heatmap && (opts.LogY || opts.YMin != nil || opts.YMax != nil) {
+		return opts, fmt.Errorf("heatmap cannot be combined with a logarithmic or fixed Y axis")
+	}
+	if opts.LegendMax < 0 {
+		return opts, fmt.Errorf("maximum number of legend entries cannot be negative")
+	}
+	if opts.TitlePadding < 0 {
+		return opts, fmt.Errorf("title padding must be positive")
+	}
+	if err := validStyle(opts.Style); err != nil {
+		return opts, err
+	}
+	if err := validPNGCompression(opts.PNGCompression); err != nil {
+		return opts, err
+	}
+	if opts.JPEGQuality < 1 || opts.JPEGQuality > 100 {
+		return opts, fmt.Errorf("JPEG quality must be between 1 and 100, got %d", opts.JPEGQuality)
+	}
+	if opts.LineWidth < 0 {
+		return opts, fmt.Errorf("line width must be positive")
+	}
+	if opts.Width < 0 || opts.Height < 0 {
+		return opts, fmt.Errorf("width and height must be positive")
+	}
+	if opts.Margin < 0 || 2*opts.Margin >= opts.Width || 2*opts.Margin >= opts.Height {
+		return opts, fmt.Errorf("margin must be positive and smaller than half of width and height")
+	}
+	return opts, nil
+}
+
+func validStyle(style string) error {
+	for _, s := range Styles {
+		if style == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown style %q, valid styles are: %s", style, strings.Join(Styles, ", "))
+}
+
+// newCanvas creates a canvas for opts.Format filled with opts.Background.
+func newCanvas(opts PlotOptions) (vg.CanvasWriterTo, error) {
+	if opts.Background == nil {
+		return draw.NewFormattedCanvas(opts.Width, opts.Height, opts.Format)
+	}
+	// Image canvases are filled with white unless they get a background color
+	img := func() *vgimg.Canvas {
+		return vgimg.NewWith(vgimg.UseWH(opts.Width, opts.Height), vgimg.UseBackgroundColor(opts.Background))
+	}
+	switch opts.Format {
+	case "jpg", "jpeg":
+		return vgimg.JpegCanvas{Canvas: img()}, nil
+	case "png":
+		return vgimg.PngCanvas{Canvas: img()}, nil
+	case "tif", "tiff":
+		return vgimg.TiffCanvas{Canvas: img()}, nil
+	}
+	c, err := draw.NewFormattedCanvas(opts.Width, opts.Height, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	// Transparent backgrounds are not drawn at all since SVG cannot encode their fill
+	if _, _, _, a := opts.Background.RGBA(); a > 0 {
+		dc := draw.New(c)
+		dc.SetColor(opts.Background)
+		dc.Fill(dc.Rectangle.Path())
+	}
+	return c, nil
+}
+
+func validPNGCompression(level string) error {
+	for _, l := range PNGCompressions {
+		if level == l {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown PNG compression %q, valid levels are: %s", level, strings.Join(PNGCompressions, ", "))
+}
+
+func pngCompressionLevel(level string) png.CompressionLevel {
+	switch level {
+	case "best-speed":
+		return png.BestSpeed
+	case "best-compression":
+		return png.BestCompression
+	}
+	return png.DefaultCompression
+}
+
+// pngPlot encodes an image as PNG with a custom compression level.
+// vgimg.PngCanvas always uses the default level.
+type pngPlot struct {
+	img   image.Image
+	level png.CompressionLevel
+}
+
+func (p pngPlot) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: p.level}
+	if err := enc.Encode(&buf, p.img); err != nil {
+		return 0, err
+	}
+	return buf.WriteTo(w)
+}
+
+// jpegPlot encodes an image as JPEG with a custom quality.
+type jpegPlot struct {
+	img     image.Image
+	quality int
+}
+
+func (p jpegPlot) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, p.img, &jpeg.Options{Quality: p.quality}); err != nil {
+		return 0, err
+	}
+	return buf.WriteTo(w)
+}
+
+// insertGaps adds a NaN point between any two points further apart than threshold.
+// A zero threshold defaults to three times the median distance between points.
+func insertGaps(data plotter.XYs, threshold time.Duration) plotter.XYs {
+	if threshold < 0 || len(data) < 3 {
+		return data
+	}
+	maxDistance := threshold.Seconds()
+	if threshold == 0 {
+		distances := make([]float64, len(data)-1)
+		for i := range distances {
+			distances[i] = data[i+1].X - data[i].X
+		}
+		sort.Float64s(distances)
+		maxDistance = 3 * distances[len(distances)/2]
+	}
+	if maxDistance <= 0 {
+		return data
+	}
+	var result plotter.XYs
+	for i, p := range data {
+		if i > 0 && p.X-data[i-1].X > maxDistance {
+			result = append(result, plotter.XY{X: (p.X + data[i-1].X) / 2, Y: math.NaN()})
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// segments splits data at points with NaN or infinite values, e.g. missing samples.
+// The points are left out so that lines show gaps instead of causing errors.
+// Data without any valid points results in a single empty segment.
+func segments(data plotter.XYs) []plotter.XYs {
+	var parts []plotter.XYs
+	start := 0
+	for i := 0; i <= len(data); i++ {
+		if i < len(data) && finite(data[i].Y) {
+			continue
+		}
+		if i > start {
+			parts = append(parts, data[start:i])
+		}
+		start = i + 1
+	}
+	if len(parts) == 0 {
+		return []plotter.XYs{{}}
+	}
+	return parts
+}
+
+// dashes returns the dash pattern of the i-th series scaled to the line width.
+func dashes(i int, width vg.Length) []vg.Length {
+	pattern := DashPatterns[i%len(DashPatterns)]
+	if pattern == nil {
+		return nil
+	}
+	scaled := make([]vg.Length, len(pattern))
+	for j, d := range pattern {
+		scaled[j] = d * width / DefaultLineWidth
+	}
+	return scaled
+}
+
+// newPlot creates a plot from metric data. Options must have defaults applied.
+func newPlot(metrics model.Matrix, opts PlotOptions) (*plot.Plot, error) {
 	p, err := plot.New()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create new plot: %v", err)
+		return nil, fmt.Errorf("failed to create new plot: %w", err)
 	}
 
-	titleFont, err := vg.MakeFont("Helvetica-Bold", vg.Centimeter)
+	titleName := opts.Font
+	if bold, ok := boldFonts[opts.Font]; ok {
+		titleName = bold
+	}
+	titleFont, err := makeFont(titleName, opts.TitleFontSize)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load font: %v", err)
+		return nil, err
 	}
-	textFont, err := vg.MakeFont("Helvetica", 3*vg.Millimeter)
+	textFont, err := makeFont(opts.Font, opts.TickFontSize)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load font: %v", err)
+		return nil, err
 	}
 
-	p.Title.Text = title
+	if opts.Background != nil {
+		// The whole canvas is filled instead
+		p.BackgroundColor = nil
+	}
+	if !opts.HideTitle {
+		p.Title.Text = opts.Title
+	}
 	p.Title.Font = titleFont
-	p.Title.Padding = 2 * vg.Centimeter
-	p.X.Tick.Marker = plot.TimeTicks{Format: "2006-01-02\n15:04"}
+	p.Title.Padding = opts.TitlePadding
+	p.X.Tick.Marker = plot.TimeTicks{Format: opts.TimeFormat, Time: plot.UnixTimeIn(opts.Location)}
 	p.X.Tick.Label.Font = textFont
 	p.Y.Tick.Label.Font = textFont
+	p.Y.Label.Text = opts.YLabel
+	p.Y.Label.Font = textFont
+	if opts.YUnit != "" {
+		p.Y.Tick.Marker = UnitTicks{Unit: opts.YUnit}
+	}
 	p.Legend.Font = textFont
-	p.Legend.Top = true
-	p.Legend.YOffs = 15 * vg.Millimeter
+	switch opts.Legend {
+	case "top":
+		// Place legend above the data area
+		p.Legend.Top = true
+		p.Legend.YOffs = 15 * vg.Millimeter
+	case "bottom":
+		p.Legend.Top = false
+	case "left":
+		p.Legend.Top = true
+		p.Legend.Left = true
+	case "right":
+		p.Legend.Top = true
+	case "none":
+	default:
+		return nil, fmt.Errorf("unknown legend position %q, valid positions are: %s", opts.Legend, strings.Join(LegendPositions, ", "))
+	}
+
+	if opts.TextColor != nil {
+		p.Title.Color = opts.TextColor
+		p.X.Tick.Label.Color = opts.TextColor
+		p.Y.Tick.Label.Color = opts.TextColor
+		p.Y.Label.Color = opts.TextColor
+		p.Legend.Color = opts.TextColor
+	}
+	if opts.AxisColor != nil {
+		p.X.Color = opts.AxisColor
+		p.Y.Color = opts.AxisColor
+		p.X.Tick.Color = opts.AxisColor
+		p.Y.Tick.Color = opts.AxisColor
+	}
+
+	if opts.Grid {
+		grid := plotter.NewGrid()
+		if opts.GridColor != nil {
+			grid.Vertical.Color = opts.GridColor
+			grid.Horizontal.Color = opts.GridColor
+		}
+		p.Add(grid)
+	}
+
+	var legendFormat *template.Template
+	if opts.LegendFormat != "" {
+		legendFormat, err = template.New("legend").Option("missingkey=zero").Parse(opts.LegendFormat)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse legend format: %w", err)
+		}
+	}
 
 	// Color palette for drawing lines
-	paletteSize := 8
-	palette, err := brewer.GetPalette(brewer.TypeAny, "Dark2", paletteSize)
+	colors, err := paletteColors(opts.Palette, opts.PaletteSize)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get color palette: %v", err)
+		return nil, err
+	}
+
+	if len(opts.KeepLabels) > 0 || len(opts.DropLabels) > 0 || opts.MergeSeries {
+		metrics = Relabel(metrics, opts.KeepLabels, opts.DropLabels, opts.MergeSeries)
+	}
+	series := sortSeries(metrics)
+	if opts.SortBy != "" {
+		series, err = SortBy(metrics, opts.SortBy)
+		if err != nil {
+			return nil, err
+		}
 	}
-	colors := palette.Colors()
 
-	for s, sample := range metrics {
+	if opts.Heatmap {
+		h, ticks, err := newHeatmap(metrics, opts.YUnit)
+		if err != nil {
+			return nil, err
+		}
+		if h != nil {
+			p.Add(h)
+			p.Y.Tick.Marker = ticks
+		}
+		// Buckets are not drawn as lines
+		series = nil
+	}
+
+	// Plotters of each series
+	layers := make([][]plot.Plotter, 0, len(series))
+	var legendEntries, hiddenEntries int
+	for s, sample := range series {
+		var layer []plot.Plotter
 		data := make(plotter.XYs, len(sample.Values))
 		for i, v := range sample.Values {
 			data[i].X = float64(v.Timestamp.Unix())
-			f, err := strconv.ParseFloat(v.Value.String(), 64)
+			data[i].Y = float64(v.Value)
+		}
+
+		data = insertGaps(data, opts.GapThreshold)
+
+		c := colors[s%len(colors)]
+		smoothed := data
+		if opts.SmoothWindow > 0 {
+			smoothed = SmoothDuration(data, opts.SmoothWindow)
+		} else if opts.SmoothPoints > 1 {
+			smoothed = SmoothPoints(data, opts.SmoothPoints)
+		}
+		// Draw raw data faded below the smoothed line
+		if opts.SmoothOverlay && (opts.SmoothWindow > 0 || opts.SmoothPoints > 1) {
+			for _, segment := range segments(data) {
+				raw, err := plotter.NewLine(segment)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create line: %w", err)
+				}
+				raw.LineStyle.Width = opts.LineWidth / 2
+				raw.LineStyle.Color = fade(c, 0x60)
+				layer = append(layer, raw)
+			}
+		}
+
+		// Lines are split at missing values, the first segment is shown in the legend
+		var thumbs []plot.Thumbnailer
+		parts := segments(smoothed)
+		// Points only still need a line without stroke for the fill
+		if opts.Style != "points" || opts.FillUnder {
+			for i, segment := range parts {
+				l, err := plotter.NewLine(segment)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create line: %w", err)
+				}
+				l.LineStyle.Width = opts.LineWidth
+				l.LineStyle.Color = c
+				if opts.Dash {
+					l.LineStyle.Dashes = dashes(s, opts.LineWidth)
+				}
+				if opts.Style == "points" {
+					l.LineStyle.Width = 0
+				}
+				if opts.FillUnder {
+					l.FillColor = fade(c, 0x40)
+				}
+				layer = append(layer, l)
+				if i == 0 {
+					thumbs = append(thumbs, l)
+				}
+			}
+		}
+		// Points without neighbors are not visible as lines, e.g. the samples of an instant vector
+		if opts.Style == "line" {
+			var single plotter.XYs
+			for _, segment := range parts {
+				if len(segment) == 1 {
+					single = append(single, segment...)
+				}
+			}
+			if len(single) > 0 {
+				sc, err := plotter.NewScatter(single)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create points: %w", err)
+				}
+				sc.GlyphStyle = draw.GlyphStyle{Color: c, Radius: 2 * opts.LineWidth, Shape: draw.CircleGlyph{}}
+				layer = append(layer, sc)
+			}
+		}
+		if opts.Style != "line" {
+			var points plotter.XYs
+			for _, segment := range parts {
+				points = append(points, segment...)
+			}
+			sc, err := plotter.NewScatter(points)
 			if err != nil {
-				return nil, fmt.Errorf("sample value not float: %s", v.Value.String())
+				return nil, fmt.Errorf("failed to create points: %w", err)
 			}
-			data[i].Y = f
+			sc.GlyphStyle = draw.GlyphStyle{Color: c, Radius: 2 * opts.LineWidth, Shape: draw.CircleGlyph{}}
+			layer = append(layer, sc)
+			thumbs = append(thumbs, sc)
 		}
+		layers = append(layers, layer)
+
+		if len(metrics) > 1 && opts.Legend != "none" {
+			label, ok, err := legendLabel(sample.Metric, legendFormat)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case !ok:
+			case opts.LegendMax > 0 && legendEntries >= opts.LegendMax:
+				hiddenEntries++
+			default:
+				p.Legend.Add(label, thumbs...)
+				legendEntries++
+			}
+		}
+	}
+	if hiddenEntries > 0 {
+		p.Legend.Add(fmt.Sprintf("... and %d more", hiddenEntries))
+	}
 
-		l, err := plotter.NewLine(data)
+	// Draw series sorted by value with the first one on top
+	if opts.SortBy != "" {
+		for i, j := 0, len(layers)-1; i < j; i, j = i+1, j-1 {
+			layers[i], layers[j] = layers[j], layers[i]
+		}
+	}
+	for _, layer := range layers {
+		p.Add(layer...)
+	}
+	if len(opts.Exemplars) > 0 {
+		points := make(plotter.XYs, len(opts.Exemplars))
+		for i, e := range opts.Exemplars {
+			points[i].X = float64(e.Time.Unix())
+			points[i].Y = e.Value
+		}
+		sc, err := plotter.NewScatter(points)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create line: %v", err)
+			return nil, fmt.Errorf("failed to create exemplars: %w", err)
+		}
+		c := opts.TextColor
+		if c == nil {
+			c = color.Black
 		}
-		l.LineStyle.Width = vg.Points(1)
-		l.LineStyle.Color = colors[s%paletteSize]
+		sc.GlyphStyle = draw.GlyphStyle{Color: c, Radius: 3 * opts.LineWidth, Shape: draw.RingGlyph{}}
+		p.Add(sc)
+	}
 
-		p.Add(l)
-		if len(metrics) > 1 {
-			m := labelText.FindStringSubmatch(sample.Metric.String())
-			if m != nil {
-				p.Legend.Add(m[1], l)
-			}
+	if err := addMarkers(p, opts, textFont); err != nil {
+		return nil, err
+	}
+
+	// Values outside of the configured bounds are not shown, so only the final range is checked for a logarithmic axis
+	fixYRange(p, opts)
+	if opts.LogY {
+		if (opts.YMin != nil && *opts.YMin <= 0) || (opts.YMax != nil && *opts.YMax <= 0) {
+			return nil, fmt.Errorf("logarithmic Y axis requires positive bounds")
+		}
+		switch {
+		case p.Y.Min > p.Y.Max:
+			// No data to plot, show an arbitrary range
+			p.Y.Min, p.Y.Max = 1, 10
+		case p.Y.Min <= 0:
+			return nil, fmt.Errorf("logarithmic Y axis requires positive values, got %v", p.Y.Min)
+		case p.Y.Min == p.Y.Max:
+			p.Y.Min, p.Y.Max = p.Y.Min/10, p.Y.Max*10
+		}
+		p.Y.Scale = logScale{}
+		if opts.YUnit == "" {
+			p.Y.Tick.Marker = plot.LogTicks{}
+		}
+	}
+	if p.X.Min == p.X.Max {
+		// All data is at a single point in time, show it in the middle of a minute
+		p.X.Min, p.X.Max = p.X.Min-30, p.X.Max+30
+	}
+	if !opts.XMin.IsZero() {
+		p.X.Min = float64(opts.XMin.Unix())
+	}
+	if !opts.XMax.IsZero() {
+		p.X.Max = float64(opts.XMax.Unix())
+	}
+	if p.X.Min >= p.X.Max && !math.IsInf(p.X.Min, 0) && !math.IsInf(p.X.Max, 0) {
+		return nil, fmt.Errorf("X axis range from %s to %s is empty", plot.UnixTimeIn(opts.Location)(p.X.Min).Format(time.RFC3339), plot.UnixTimeIn(opts.Location)(p.X.Max).Format(time.RFC3339))
+	}
+
+	return p, nil
+}
+
+// logScale is a logarithmic scale which also accepts values that are not positive.
+// Those only occur below a configured minimum and are moved far below the axis, where they are clipped like other values outside of the range.
+type logScale struct{}
+
+func (logScale) Normalize(min, max, x float64) float64 {
+	if x <= 0 {
+		x = min * 1e-6
+	}
+	return plot.LogScale{}.Normalize(min, max, x)
+}
+
+// fixYRange sets the configured bounds of the Y axis.
+// If only one bound is set and the data lies completely beyond it,
+// the other bound is moved to keep the range valid.
+func fixYRange(p *plot.Plot, opts PlotOptions) {
+	if opts.YMin != nil {
+		p.Y.Min = *opts.YMin
+	}
+	if opts.YMax != nil {
+		p.Y.Max = *opts.YMax
+	}
+	if p.Y.Min < p.Y.Max {
+		return
+	}
+	switch {
+	case opts.YMin != nil && opts.YMax == nil && opts.LogY:
+		p.Y.Max = p.Y.Min * 10
+	case opts.YMin != nil && opts.YMax == nil:
+		p.Y.Max = p.Y.Min + 1
+	case opts.YMax != nil && opts.YMin == nil && opts.LogY:
+		p.Y.Min = p.Y.Max / 10
+	case opts.YMax != nil && opts.YMin == nil:
+		p.Y.Min = p.Y.Max - 1
+	}
+}
+
+// legendLabel creates the legend entry for a series.
+// Without a format the labels are extracted from the metric name.
+func legendLabel(metric model.Metric, format *template.Template) (string, bool, error) {
+	if format == nil {
+		m := labelText.FindStringSubmatch(metric.String())
+		if m == nil {
+			return "", false, nil
 		}
+		return m[1], true, nil
+	}
+	labels := make(map[string]string, len(metric))
+	for k, v := range metric {
+		labels[string(k)] = string(v)
 	}
+	var b strings.Builder
+	if err := format.Execute(&b, labels); err != nil {
+		return "", false, fmt.Errorf("failed to execute legend format: %w", err)
+	}
+	return b.String(), true, nil
+}
 
-	// Draw plot in canvas with margin
-	margin := 6 * vg.Millimeter
-	width := 24 * vg.Centimeter
-	height := 20 * vg.Centimeter
-	c, err := draw.NewFormattedCanvas(width, height, format)
+// fade returns c with the given alpha.
+func fade(c color.Color, alpha uint8) color.Color {
+	r, g, b, _ := c.RGBA()
+	return color.NRGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: alpha}
+}
+
+func paletteColors(name string, size int) ([]color.Color, error) {
+	if !isPalette(name) {
+		return nil, fmt.Errorf("unknown color palette %q, valid palettes are: %s", name, strings.Join(PaletteNames(), ", "))
+	}
+	palette, err := brewer.GetPalette(brewer.TypeAny, name, size)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create canvas: %v", err)
+		return nil, fmt.Errorf("failed to get color palette: %w", err)
 	}
-	p.Draw(draw.Crop(draw.New(c), margin, -margin, margin, -margin))
+	return palette.Colors(), nil
+}
 
-	return c, nil
+func isPalette(name string) bool {
+	_, diverging := brewer.DivergingPalettes[name]
+	_, qualitative := brewer.QualitativePalettes[name]
+	_, sequential := brewer.SequentialPalettes[name]
+	return diverging || qualitative || sequential
+}
+
+// PaletteNames returns the sorted names of all available Brewer color palettes.
+func PaletteNames() []string {
+	var names []string
+	for n := range brewer.DivergingPalettes {
+		names = append(names, n)
+	}
+	for n := range brewer.QualitativePalettes {
+		names = append(names, n)
+	}
+	for n := range brewer.SequentialPalettes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
 }
@@ -0,0 +1,813 @@
+package promplot
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// testMatrix creates a matrix with n series of a few samples each.
+func testMatrix(n int) model.Matrix {
+	var m model.Matrix
+	for i := 0; i < n; i++ {
+		var values []float64
+		for j := 0; j < 5; j++ {
+			values = append(values, float64(i+j))
+		}
+		m = append(m, testSeries(model.Metric{"series": model.LabelValue(strings.Repeat("a", i+1))}, values...))
+	}
+	return m
+}
+
+// testSeries creates a series with samples one minute apart, starting at the same time as testMatrix.
+func testSeries(metric model.Metric, values ...float64) *model.SampleStream {
+	s := &model.SampleStream{Metric: metric}
+	for i, v := range values {
+		s.Values = append(s.Values, model.SamplePair{
+			Timestamp: model.TimeFromUnix(int64(1500000000 + 60*i)),
+			Value:     model.SampleValue(v),
+		})
+	}
+	return s
+}
+
+func TestPlotPalette(t *testing.T) {
+	tests := []struct {
+		palette string
+		size    int
+		invalid bool
+	}{
+		{palette: "", size: 0},
+		{palette: "Set1", size: 3},
+		{palette: "Paired", size: 12},
+		{palette: "Unknown", invalid: true},
+		{palette: "Set1", size: 2, invalid: true},
+		{palette: "Set1", size: 100, invalid: true},
+	}
+
+	for i, tt := range tests {
+		// More series than colors to check wrapping around the palette
+		_, err := PlotWithOptions(testMatrix(20), PlotOptions{Format: "png", Palette: tt.palette, PaletteSize: tt.size})
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("%d. plot failed unexpectedly: %v", i, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("%d. plot should have failed", i)
+		}
+	}
+}
+
+func TestPlotDimensions(t *testing.T) {
+	tests := []struct {
+		width, height, margin vg.Length
+		invalid               bool
+	}{
+		{},
+		{width: 800, height: 600, margin: 10},
+		{width: 10 * vg.Centimeter},
+		{width: -1, invalid: true},
+		{height: -1, invalid: true},
+		{margin: -1, invalid: true},
+		{width: 10, height: 10, margin: 5, invalid: true},
+	}
+
+	for i, tt := range tests {
+		_, err := PlotWithOptions(testMatrix(1), PlotOptions{Format: "png", Width: tt.width, Height: tt.height, Margin: tt.margin})
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("%d. plot failed unexpectedly: %v", i, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("%d. plot should have failed", i)
+		}
+	}
+}
+
+func TestPlotLegend(t *testing.T) {
+	tests := []struct {
+		legend  string
+		invalid bool
+	}{
+		{legend: ""},
+		{legend: "top"},
+		{legend: "bottom"},
+		{legend: "left"},
+		{legend: "right"},
+		{legend: "none"},
+		{legend: "center", invalid: true},
+	}
+
+	for i, tt := range tests {
+		_, err := PlotWithOptions(testMatrix(3), PlotOptions{Format: "png", Legend: tt.legend})
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("%d. plot failed unexpectedly: %v", i, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("%d. plot should have failed", i)
+		}
+	}
+}
+
+func TestPlotLegendMax(t *testing.T) {
+	tests := []struct {
+		max     int
+		entries []string
+		hidden  string
+	}{
+		{max: 0, entries: []string{`series="a"`, `series="aa"`, `series="aaa"`, `series="aaaa"`}},
+		{max: 2, entries: []string{`series="a"`, `series="aa"`}, hidden: "... and 2 more"},
+		{max: 4, entries: []string{`series="a"`, `series="aa"`, `series="aaa"`, `series="aaaa"`}},
+	}
+
+	for _, tt := range tests {
+		plot, err := PlotWithOptions(testMatrix(4), PlotOptions{Format: "svg", LegendMax: tt.max})
+		if err != nil {
+			t.Fatalf("%d: plot failed unexpectedly: %v", tt.max, err)
+		}
+		var buf bytes.Buffer
+		if _, err := plot.WriteTo(&buf); err != nil {
+			t.Fatalf("%d: writing failed unexpectedly: %v", tt.max, err)
+		}
+		svg := strings.NewReplacer("&#34;", `"`, "&quot;", `"`).Replace(buf.String())
+		entries := strings.Count(svg, `>series=`)
+		if entries != len(tt.entries) {
+			t.Errorf("%d: expected %d legend entries, got %d", tt.max, len(tt.entries), entries)
+		}
+		for _, e := range tt.entries {
+			if !strings.Contains(svg, ">"+e+"<") {
+				t.Errorf("%d: missing legend entry %s", tt.max, e)
+			}
+		}
+		if (tt.hidden != "") != strings.Contains(svg, "more<") || !strings.Contains(svg, tt.hidden) {
+			t.Errorf("%d: expected hidden entry %q", tt.max, tt.hidden)
+		}
+	}
+
+	if _, err := PlotWithOptions(testMatrix(2), PlotOptions{Format: "png", LegendMax: -1}); err == nil {
+		t.Error("negative maximum should fail")
+	}
+}
+
+func TestPlotJPEGQuality(t *testing.T) {
+	sizes := map[int]int{}
+	for _, quality := range []int{1, 50, 100, 0, DefaultJPEGQuality} {
+		plot, err := PlotWithOptions(testMatrix(3), PlotOptions{Format: "jpg", JPEGQuality: quality})
+		if err != nil {
+			t.Fatalf("%d: plot failed unexpectedly: %v", quality, err)
+		}
+		var buf bytes.Buffer
+		n, err := plot.WriteTo(&buf)
+		if err != nil {
+			t.Fatalf("%d: writing failed unexpectedly: %v", quality, err)
+		}
+		if n != int64(buf.Len()) {
+			t.Errorf("%d: reported %d bytes but wrote %d", quality, n, buf.Len())
+		}
+		if _, err := jpeg.Decode(&buf); err != nil {
+			t.Errorf("%d: invalid JPEG: %v", quality, err)
+		}
+		sizes[quality] = int(n)
+	}
+	if !(sizes[1] < sizes[50] && sizes[50] < sizes[100]) {
+		t.Errorf("higher quality should create larger files: %v", sizes)
+	}
+	if sizes[0] != sizes[DefaultJPEGQuality] {
+		t.Errorf("default quality should be used without a quality: %v", sizes)
+	}
+	for _, quality := range []int{-1, 101} {
+		if _, err := PlotWithOptions(testMatrix(1), PlotOptions{Format: "jpg", JPEGQuality: quality}); err == nil {
+			t.Errorf("%d: quality should be invalid", quality)
+		}
+	}
+}
+
+func TestLegendLabel(t *testing.T) {
+	metric := model.Metric{"__name__": "up", "instance": "host:9100", "job": "node"}
+	tests := []struct {
+		format string
+		label  string
+	}{
+		{format: "", label: `instance="host:9100", job="node"`},
+		{format: "{{.instance}} ({{.job}})", label: "host:9100 (node)"},
+		{format: "{{.instance}} {{.missing}}", label: "host:9100 "},
+	}
+
+	for i, tt := range tests {
+		var format *template.Template
+		if tt.format != "" {
+			format = template.Must(template.New("").Option("missingkey=zero").Parse(tt.format))
+		}
+		label, _, err := legendLabel(metric, format)
+		if err != nil {
+			t.Errorf("%d. creating label failed unexpectedly: %v", i, err)
+			continue
+		}
+		if label != tt.label {
+			t.Errorf(`
+%d.
+Format:   %s
+Expected: %s
+Got       %s`, i, tt.format, tt.label, label)
+		}
+	}
+
+	if _, err := PlotWithOptions(testMatrix(2), PlotOptions{Format: "png", LegendFormat: "{{.series"}); err == nil {
+		t.Error("plot with invalid legend format should have failed")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+func TestWritePlot(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WritePlot(testMatrix(2), "title", "png", &buf); err != nil {
+		t.Fatalf("writing plot failed unexpectedly: %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("\x89PNG")) {
+		t.Error("expected PNG output")
+	}
+
+	err := WritePlot(testMatrix(2), "title", "unknown", &buf)
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to create plot") {
+		t.Errorf("expected plot error, got: %v", err)
+	}
+	err = WritePlot(testMatrix(2), "title", "png", failingWriter{})
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to write plot") {
+		t.Errorf("expected write error, got: %v", err)
+	}
+}
+
+func TestDataURI(t *testing.T) {
+	for _, format := range []string{"png", "svg"} {
+		uri, err := DataURI(testMatrix(2), "title", format)
+		if err != nil {
+			t.Fatalf("creating %s data URI failed unexpectedly: %v", format, err)
+		}
+		prefix := "data:" + ContentType(format) + ";base64,"
+		if !strings.HasPrefix(uri, prefix) {
+			t.Fatalf("unexpected %s data URI: %.40s", format, uri)
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(uri, prefix))
+		if err != nil {
+			t.Fatalf("invalid base64 in %s data URI: %v", format, err)
+		}
+		var buf bytes.Buffer
+		if err := WritePlot(testMatrix(2), "title", format, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, buf.Bytes()) {
+			t.Errorf("%s data URI does not contain the plot", format)
+		}
+	}
+
+	_, err := DataURI(testMatrix(2), "title", "unknown")
+	if !errors.Is(err, ErrPlot) {
+		t.Errorf("expected plot error, got: %v", err)
+	}
+}
+
+func TestValidFormat(t *testing.T) {
+	for _, f := range Formats {
+		if err := ValidFormat(f); err != nil {
+			t.Errorf("format %s should be valid: %v", f, err)
+		}
+		if _, err := Plot(testMatrix(1), "title", f); err != nil {
+			t.Errorf("plotting %s failed unexpectedly: %v", f, err)
+		}
+	}
+	for _, f := range []string{"", "gif", "PNG"} {
+		if err := ValidFormat(f); err == nil {
+			t.Errorf("format %q should be invalid", f)
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{format: "eps", expected: "application/postscript"},
+		{format: "jpg", expected: "image/jpeg"},
+		{format: "jpeg", expected: "image/jpeg"},
+		{format: "pdf", expected: "application/pdf"},
+		{format: "png", expected: "image/png"},
+		{format: "svg", expected: "image/svg+xml"},
+		{format: "tex", expected: "application/x-tex"},
+		{format: "tif", expected: "image/tiff"},
+		{format: "tiff", expected: "image/tiff"},
+		{format: "csv", expected: "text/csv"},
+		{format: "gif", expected: "application/octet-stream"},
+		{format: "", expected: "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		if ct := ContentType(tt.format); ct != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.format, tt.expected, ct)
+		}
+	}
+	// All formats must be covered
+	for _, f := range Formats {
+		if ContentType(f) == "application/octet-stream" {
+			t.Errorf("missing content type for format %s", f)
+		}
+	}
+}
+
+func TestPlotTimeFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+		invalid  bool
+	}{
+		{format: "", expected: DefaultTimeFormat},
+		{format: "15:04:05", expected: "15:04:05"},
+		{format: "Jan 02", expected: "Jan 02"},
+		{format: "no time", invalid: true},
+	}
+
+	for i, tt := range tests {
+		opts, err := PlotOptions{Format: "png", TimeFormat: tt.format}.withDefaults()
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("%d. options failed unexpectedly: %v", i, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("%d. options should have failed", i)
+			continue
+		}
+		p, err := newPlot(testMatrix(1), opts)
+		if err != nil {
+			t.Errorf("%d. plot failed unexpectedly: %v", i, err)
+			continue
+		}
+		marker, ok := p.X.Tick.Marker.(plot.TimeTicks)
+		if !ok {
+			t.Errorf("%d. unexpected marker type: %T", i, p.X.Tick.Marker)
+			continue
+		}
+		if marker.Format != tt.expected {
+			t.Errorf("%d. expected format %q, got %q", i, tt.expected, marker.Format)
+		}
+	}
+}
+
+func TestTimeFormatFor(t *testing.T) {
+	for _, d := range []time.Duration{time.Minute, time.Hour, 6 * time.Hour, 7 * 24 * time.Hour, 90 * 24 * time.Hour} {
+		if err := ValidTimeFormat(TimeFormatFor(d)); err != nil {
+			t.Errorf("invalid format for %v: %v", d, err)
+		}
+	}
+	if TimeFormatFor(30*time.Minute) != "15:04:05" {
+		t.Error("expected seconds for sub-hour ranges")
+	}
+	if TimeFormatFor(90*24*time.Hour) != "2006-01-02" {
+		t.Error("expected dates for multi-month ranges")
+	}
+}
+
+func TestPlotLocation(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+	tests := []struct {
+		loc      *time.Location
+		expected string
+	}{
+		{loc: nil, expected: "UTC"},
+		{loc: time.UTC, expected: "UTC"},
+		{loc: ny, expected: "America/New_York"},
+	}
+
+	for i, tt := range tests {
+		opts, err := PlotOptions{Format: "png", Location: tt.loc}.withDefaults()
+		if err != nil {
+			t.Fatalf("%d. options failed unexpectedly: %v", i, err)
+		}
+		p, err := newPlot(testMatrix(1), opts)
+		if err != nil {
+			t.Fatalf("%d. plot failed unexpectedly: %v", i, err)
+		}
+		loc := p.X.Tick.Marker.(plot.TimeTicks).Time(1500000000).Location().String()
+		if loc != tt.expected {
+			t.Errorf("%d. expected location %s, got %s", i, tt.expected, loc)
+		}
+	}
+}
+
+func TestPlotLineStyles(t *testing.T) {
+	tests := []PlotOptions{
+		{},
+		{SmoothPoints: 3},
+		{SmoothWindow: time.Minute},
+		{SmoothPoints: 3, SmoothOverlay: true},
+		{SmoothWindow: time.Minute, SmoothOverlay: true},
+		{SmoothOverlay: true},
+		{FillUnder: true},
+		{SmoothPoints: 3, SmoothOverlay: true, FillUnder: true},
+		{SortBy: "max", SmoothPoints: 3, SmoothOverlay: true},
+		{LineWidth: 3},
+		{Dash: true},
+		{Dash: true, LineWidth: 0.5, SmoothPoints: 3, SmoothOverlay: true},
+		{Style: "line"},
+		{Style: "points"},
+		{Style: "linepoints"},
+		{Style: "points", FillUnder: true},
+		{Style: "linepoints", Dash: true, SmoothPoints: 3, SmoothOverlay: true},
+	}
+
+	for i, opts := range tests {
+		opts.Format = "png"
+		if _, err := PlotWithOptions(testMatrix(2), opts); err != nil {
+			t.Errorf("%d. plot failed unexpectedly: %v", i, err)
+		}
+	}
+	if _, err := PlotWithOptions(testMatrix(1), PlotOptions{Format: "png", Style: "bars"}); err == nil {
+		t.Error("unknown style should be invalid")
+	}
+}
+
+func TestPlotPNGCompression(t *testing.T) {
+	sizes := map[string]int{}
+	for _, level := range append(PNGCompressions, "") {
+		plot, err := PlotWithOptions(testMatrix(3), PlotOptions{Format: "png", PNGCompression: level})
+		if err != nil {
+			t.Fatalf("%q: plot failed unexpectedly: %v", level, err)
+		}
+		var buf bytes.Buffer
+		n, err := plot.WriteTo(&buf)
+		if err != nil {
+			t.Fatalf("%q: writing failed unexpectedly: %v", level, err)
+		}
+		if n != int64(buf.Len()) {
+			t.Errorf("%q: reported %d bytes but wrote %d", level, n, buf.Len())
+		}
+		if _, err := png.Decode(&buf); err != nil {
+			t.Errorf("%q: invalid PNG: %v", level, err)
+		}
+		sizes[level] = int(n)
+	}
+	if sizes["best-compression"] >= sizes["best-speed"] {
+		t.Errorf("best compression should create smaller files than best speed: %v", sizes)
+	}
+	if sizes[""] != sizes["default"] {
+		t.Errorf("default compression should be used without a level: %v", sizes)
+	}
+	if _, err := PlotWithOptions(testMatrix(1), PlotOptions{Format: "png", PNGCompression: "fast"}); err == nil {
+		t.Error("unknown PNG compression should be invalid")
+	}
+}
+
+func TestPlotBackground(t *testing.T) {
+	dark := color.NRGBA{R: 30, G: 30, B: 30, A: 255}
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	tests := []struct {
+		background color.Color
+		// Expected colors in the margin and inside the plot area
+		margin, inside color.NRGBA
+	}{
+		{background: nil, margin: white, inside: white},
+		{background: dark, margin: dark, inside: dark},
+		{background: color.Transparent, margin: color.NRGBA{}, inside: color.NRGBA{}},
+	}
+
+	for i, tt := range tests {
+		plot, err := PlotWithOptions(testMatrix(1), PlotOptions{Format: "png", Background: tt.background})
+		if err != nil {
+			t.Fatalf("%d. plot failed unexpectedly: %v", i, err)
+		}
+		var buf bytes.Buffer
+		if _, err := plot.WriteTo(&buf); err != nil {
+			t.Fatalf("%d. writing failed unexpectedly: %v", i, err)
+		}
+		img, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatalf("%d. invalid PNG: %v", i, err)
+		}
+		if c := color.NRGBAModel.Convert(img.At(1, 1)); c != tt.margin {
+			t.Errorf("%d. expected margin color %v, got %v", i, tt.margin, c)
+		}
+		if c := color.NRGBAModel.Convert(img.At(30, 30)); c != tt.inside {
+			t.Errorf("%d. expected background color %v, got %v", i, tt.inside, c)
+		}
+	}
+
+	// Vector formats are filled instead
+	for _, bg := range []color.Color{dark, color.Transparent} {
+		plot, err := PlotWithOptions(testMatrix(1), PlotOptions{Format: "svg", Background: bg})
+		if err != nil {
+			t.Fatalf("plot failed unexpectedly: %v", err)
+		}
+		var buf bytes.Buffer
+		if _, err := plot.WriteTo(&buf); err != nil {
+			t.Fatalf("writing failed unexpectedly: %v", err)
+		}
+		filled := strings.Contains(buf.String(), "fill:#1E1E1E")
+		if filled != (bg == dark) {
+			t.Errorf("%v: unexpected background fill in SVG", bg)
+		}
+		if strings.Contains(buf.String(), "fill:#FFFFFF") {
+			t.Errorf("%v: plot area should not be white", bg)
+		}
+	}
+}
+
+func TestDashes(t *testing.T) {
+	if d := dashes(0, DefaultLineWidth); d != nil {
+		t.Errorf("first series should be solid, got %v", d)
+	}
+	if d := dashes(len(DashPatterns), DefaultLineWidth); d != nil {
+		t.Errorf("patterns should be cycled, got %v", d)
+	}
+	if d := dashes(1, 2*DefaultLineWidth); !reflect.DeepEqual(d, []vg.Length{12, 6}) {
+		t.Errorf("pattern should scale with line width, got %v", d)
+	}
+	if _, err := PlotWithOptions(testMatrix(1), PlotOptions{Format: "png", LineWidth: -1}); err == nil {
+		t.Error("negative line width should be invalid")
+	}
+}
+
+func TestSegments(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		ys       []float64
+		segments [][]float64
+	}{
+		{ys: nil, segments: [][]float64{{}}},
+		{ys: []float64{1, 2, 3}, segments: [][]float64{{1, 2, 3}}},
+		{ys: []float64{1, nan, 3, 4}, segments: [][]float64{{1}, {3, 4}}},
+		{ys: []float64{nan, 2, math.Inf(1), math.Inf(-1), 5, nan}, segments: [][]float64{{2}, {5}}},
+		{ys: []float64{nan, nan}, segments: [][]float64{{}}},
+	}
+
+	for i, tt := range tests {
+		data := make(plotter.XYs, len(tt.ys))
+		for j, y := range tt.ys {
+			data[j] = plotter.XY{X: float64(j), Y: y}
+		}
+		var parts [][]float64
+		for _, segment := range segments(data) {
+			ys := []float64{}
+			for _, p := range segment {
+				ys = append(ys, p.Y)
+			}
+			parts = append(parts, ys)
+		}
+		if !reflect.DeepEqual(parts, tt.segments) {
+			t.Errorf(`
+%d.
+Expected: %v
+Got       %v`, i, tt.segments, parts)
+		}
+	}
+}
+
+func TestInsertGaps(t *testing.T) {
+	tests := []struct {
+		xs        []float64
+		threshold time.Duration
+		gaps      []float64
+	}{
+		{xs: []float64{0, 60, 120, 180}},
+		{xs: []float64{0, 60, 120, 600, 660}, gaps: []float64{360}},
+		{xs: []float64{0, 60, 120, 300, 360}, threshold: 2 * time.Minute, gaps: []float64{210}},
+		{xs: []float64{0, 60, 120, 300, 360}, threshold: 5 * time.Minute},
+		{xs: []float64{0, 60, 120, 6000, 6060}, threshold: -1},
+		{xs: []float64{0, 0, 0, 60}},
+		{xs: []float64{0, 6000}},
+	}
+
+	for i, tt := range tests {
+		data := make(plotter.XYs, len(tt.xs))
+		for j, x := range tt.xs {
+			data[j] = plotter.XY{X: x, Y: 1}
+		}
+		var gaps []float64
+		result := insertGaps(data, tt.threshold)
+		for _, p := range result {
+			if math.IsNaN(p.Y) {
+				gaps = append(gaps, p.X)
+			}
+		}
+		if len(result) != len(data)+len(gaps) || !reflect.DeepEqual(gaps, tt.gaps) {
+			t.Errorf(`
+%d.
+Expected gaps: %v
+Got            %v`, i, tt.gaps, gaps)
+		}
+	}
+}
+
+func TestPlotNaN(t *testing.T) {
+	metrics := testMatrix(2)
+	metrics[0].Values[2].Value = model.SampleValue(math.NaN())
+	metrics[1].Values[1].Value = model.SampleValue(math.Inf(1))
+	for _, opts := range []PlotOptions{
+		{},
+		{Style: "linepoints", FillUnder: true},
+		{SmoothPoints: 3, SmoothOverlay: true},
+		{Style: "points", SortBy: "avg"},
+	} {
+		opts.Format = "png"
+		if _, err := PlotWithOptions(metrics, opts); err != nil {
+			t.Errorf("plotting with gaps failed unexpectedly: %v", err)
+		}
+	}
+
+	// The Y range ignores the gaps
+	opts, err := PlotOptions{Format: "png"}.withDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := newPlot(metrics, opts)
+	if err != nil {
+		t.Fatalf("plot failed unexpectedly: %v", err)
+	}
+	if math.IsInf(p.Y.Max, 0) || p.Y.Max != 5 {
+		t.Errorf("expected Y range up to 5, got %v", p.Y.Max)
+	}
+}
+
+func TestPlotLogY(t *testing.T) {
+	positive := testMatrix(2)[1:]
+	constant := model.Matrix{{Values: []model.SamplePair{{Timestamp: 0, Value: 5}, {Timestamp: 60000, Value: 5}}}}
+	f := func(v float64) *float64 { return &v }
+	tests := []struct {
+		metrics model.Matrix
+		min     *float64
+		max     *float64
+		invalid bool
+	}{
+		{metrics: positive},
+		{metrics: constant},
+		{metrics: nil},
+		{metrics: testMatrix(2), invalid: true},
+		// Zero samples of testMatrix(2) are below the configured minimum
+		{metrics: testMatrix(2), min: f(1)},
+		{metrics: testMatrix(2), max: f(4), invalid: true},
+	}
+
+	for i, tt := range tests {
+		plot, err := PlotWithOptions(tt.metrics, PlotOptions{Format: "png", LogY: true, Grid: true, YMin: tt.min, YMax: tt.max})
+		if err == nil {
+			_, err = plot.WriteTo(ioutil.Discard)
+		}
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("%d. plot failed unexpectedly: %v", i, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("%d. plot should have failed", i)
+		}
+	}
+}
+
+func TestPlotYRange(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	// Data of testMatrix(2) is between 0 and 5
+	tests := []struct {
+		min, max *float64
+		logY     bool
+		expected [2]float64
+		invalid  bool
+	}{
+		{expected: [2]float64{0, 5}},
+		{min: f(-10), expected: [2]float64{-10, 5}},
+		{max: f(100), expected: [2]float64{0, 100}},
+		{min: f(1), max: f(3), expected: [2]float64{1, 3}},
+		{min: f(10), expected: [2]float64{10, 11}},
+		{max: f(-10), expected: [2]float64{-11, -10}},
+		{min: f(3), max: f(3), invalid: true},
+		{min: f(4), max: f(2), invalid: true},
+		{min: f(0.1), max: f(1000), logY: true, expected: [2]float64{0.1, 1000}},
+		{min: f(0), logY: true, invalid: true},
+	}
+
+	for i, tt := range tests {
+		metrics := testMatrix(2)
+		if tt.logY {
+			metrics = metrics[1:]
+		}
+		opts, err := PlotOptions{Format: "png", YMin: tt.min, YMax: tt.max, LogY: tt.logY}.withDefaults()
+		var p *plot.Plot
+		if err == nil {
+			p, err = newPlot(metrics, opts)
+		}
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("%d. plot failed unexpectedly: %v", i, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("%d. plot should have failed", i)
+			continue
+		}
+		if got := [2]float64{p.Y.Min, p.Y.Max}; got != tt.expected {
+			t.Errorf("%d. expected Y range %v, got %v", i, tt.expected, got)
+		}
+	}
+}
+
+func TestPlotXRange(t *testing.T) {
+	// Data of testMatrix(2) is between 1500000000 and 1500000240
+	tests := []struct {
+		min, max int64
+		expected [2]float64
+		invalid  bool
+	}{
+		{expected: [2]float64{1500000000, 1500000240}},
+		{min: 1500000060, expected: [2]float64{1500000060, 1500000240}},
+		{max: 1500000120, expected: [2]float64{1500000000, 1500000120}},
+		{min: 1500000060, max: 1500000120, expected: [2]float64{1500000060, 1500000120}},
+		{min: 1400000000, max: 1600000000, expected: [2]float64{1400000000, 1600000000}},
+		{min: 1500000120, max: 1500000120, invalid: true},
+		{min: 1500000120, max: 1500000060, invalid: true},
+		{min: 1600000000, invalid: true},
+	}
+
+	for i, tt := range tests {
+		opts := PlotOptions{Format: "png"}
+		if tt.min != 0 {
+			opts.XMin = time.Unix(tt.min, 0)
+		}
+		if tt.max != 0 {
+			opts.XMax = time.Unix(tt.max, 0)
+		}
+		opts, err := opts.withDefaults()
+		var p *plot.Plot
+		if err == nil {
+			p, err = newPlot(testMatrix(2), opts)
+		}
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("%d. plot failed unexpectedly: %v", i, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("%d. plot should have failed", i)
+			continue
+		}
+		if got := [2]float64{p.X.Min, p.X.Max}; got != tt.expected {
+			t.Errorf("%d. expected X range %v, got %v", i, tt.expected, got)
+		}
+	}
+}
+
+func TestPlotFont(t *testing.T) {
+	tests := []struct {
+		opts    PlotOptions
+		invalid bool
+	}{
+		{opts: PlotOptions{}},
+		{opts: PlotOptions{Font: "Times-Roman", TitleFontSize: 5 * vg.Millimeter, TickFontSize: 4 * vg.Millimeter}},
+		{opts: PlotOptions{Font: "Courier-Oblique"}},
+		{opts: PlotOptions{Font: "Comic Sans"}, invalid: true},
+		{opts: PlotOptions{TickFontSize: -1}, invalid: true},
+	}
+
+	for i, tt := range tests {
+		tt.opts.Format = "png"
+		_, err := PlotWithOptions(testMatrix(2), tt.opts)
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("%d. plot failed unexpectedly: %v", i, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("%d. plot should have failed", i)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package promplot
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ParseProxy parses the URL of an HTTP, HTTPS or SOCKS5 proxy like http://proxy:3128 or socks5://localhost:1080.
+func ParseProxy(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, must be http, https or socks5", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", rawURL)
+	}
+	return u, nil
+}
+
+// proxyTransport returns a copy of the default transport sending all requests through proxy
+// instead of the proxy configured with HTTP_PROXY and HTTPS_PROXY.
+func proxyTransport(proxy *url.URL) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyURL(proxy)
+	return t
+}
@@ -0,0 +1,57 @@
+package promplot
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// Placeholders like $__range that can be used in queries
+var placeholder = regexp.MustCompile(`\$__\w+`)
+
+// DefaultScrapeInterval is the scrape interval assumed for $__rate_interval, the same as in Grafana.
+const DefaultScrapeInterval = 15 * time.Second
+
+// ExpandQuery replaces time variables in a query, similar to the ones supported by Grafana:
+// $__range is replaced by the queried time range,
+// $__interval by the step between data points
+// and $__rate_interval by RateInterval using DefaultScrapeInterval.
+// Unknown variables result in an error.
+func ExpandQuery(query string, duration, interval time.Duration) (string, error) {
+	return ExpandQueryWithScrapeInterval(query, duration, interval, DefaultScrapeInterval)
+}
+
+// ExpandQueryWithScrapeInterval replaces time variables like ExpandQuery,
+// using scrapeInterval as the scrape interval of the queried metrics for $__rate_interval.
+func ExpandQueryWithScrapeInterval(query string, duration, interval, scrapeInterval time.Duration) (string, error) {
+	values := map[string]string{
+		"$__range":         model.Duration(duration).String(),
+		"$__interval":      model.Duration(interval).String(),
+		"$__rate_interval": model.Duration(RateInterval(interval, scrapeInterval)).String(),
+	}
+	var unknown []string
+	expanded := placeholder.ReplaceAllStringFunc(query, func(p string) string {
+		v, ok := values[p]
+		if !ok {
+			unknown = append(unknown, p)
+			return p
+		}
+		return v
+	})
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("unknown variable in query: %s", unknown[0])
+	}
+	return expanded, nil
+}
+
+// RateInterval returns the range to use in rate and increase for a step:
+// the larger of four steps and one step plus the scrape interval.
+// Ranges shorter than that can contain too few samples and leave gaps in the plot.
+func RateInterval(step, scrapeInterval time.Duration) time.Duration {
+	if long := step + scrapeInterval; long > 4*step {
+		return long
+	}
+	return 4 * step
+}
@@ -0,0 +1,94 @@
+package promplot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpandQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		expanded string
+		invalid  bool
+	}{
+		{query: "up", expanded: "up"},
+		{query: "rate(http_requests_total[$__interval])", expanded: "rate(http_requests_total[1m])"},
+		{query: "increase(errors_total[$__range]) / $__range", expanded: "increase(errors_total[1d]) / 1d"},
+		{query: "avg_over_time(up[$__interval]) + max_over_time(up[$__range])", expanded: "avg_over_time(up[1m]) + max_over_time(up[1d])"},
+		// 4 steps are longer than a step plus the default scrape interval of 15s
+		{query: "rate(http_requests_total[$__rate_interval])", expanded: "rate(http_requests_total[4m])"},
+		{query: "rate(up[$__unknown])", invalid: true},
+		{query: "rate(up[$__intervals])", invalid: true},
+	}
+
+	for i, tt := range tests {
+		expanded, err := ExpandQuery(tt.query, 24*time.Hour, time.Minute)
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("expanding '%s' failed unexpectedly: %v", tt.query, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("expanding '%s' should have failed", tt.query)
+			continue
+		}
+		if expanded != tt.expanded {
+			t.Errorf(`
+%d.
+Input:    %s
+Expected: %s
+Got       %s`, i, tt.query, tt.expanded, expanded)
+		}
+	}
+}
+
+func TestExpandRateInterval(t *testing.T) {
+	tests := []struct {
+		query    string
+		step     time.Duration
+		scrape   time.Duration
+		expanded string
+	}{
+		// Panel queries copied from Grafana dashboards
+		{
+			query:    `sum by (code) (rate(http_requests_total{job="api"}[$__rate_interval]))`,
+			step:     time.Minute,
+			scrape:   15 * time.Second,
+			expanded: `sum by (code) (rate(http_requests_total{job="api"}[4m]))`,
+		},
+		{
+			query:    `histogram_quantile(0.99, sum by (le) (rate(http_request_duration_seconds_bucket[$__rate_interval])))`,
+			step:     15 * time.Second,
+			scrape:   time.Minute,
+			expanded: `histogram_quantile(0.99, sum by (le) (rate(http_request_duration_seconds_bucket[1m15s])))`,
+		},
+		{
+			query:    `increase(errors_total[$__rate_interval]) / increase(requests_total[$__rate_interval])`,
+			step:     10 * time.Second,
+			scrape:   30 * time.Second,
+			expanded: `increase(errors_total[40s]) / increase(requests_total[40s])`,
+		},
+		{
+			query:    `rate(node_cpu_seconds_total[$__rate_interval]) * $__interval`,
+			step:     2 * time.Second,
+			scrape:   10 * time.Second,
+			expanded: `rate(node_cpu_seconds_total[12s]) * 2s`,
+		},
+	}
+
+	for i, tt := range tests {
+		expanded, err := ExpandQueryWithScrapeInterval(tt.query, time.Hour, tt.step, tt.scrape)
+		if err != nil {
+			t.Errorf("%d. expanding failed unexpectedly: %v", i, err)
+			continue
+		}
+		if expanded != tt.expanded {
+			t.Errorf(`
+%d.
+Input:    %s
+Expected: %s
+Got       %s`, i, tt.query, tt.expanded, expanded)
+		}
+	}
+}
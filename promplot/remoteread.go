@@ -0,0 +1,335 @@
+package promplot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Version of the remote read protocol sent with every request
+const remoteReadVersion = "0.1.0"
+
+// MetricsRemoteRead fetches raw samples using the Prometheus remote read protocol.
+// This allows reading from long-term storage which does not offer the HTTP query API.
+// The endpoint is the full URL, e.g. http://localhost:9090/api/v1/read.
+// Remote read does not evaluate PromQL so query must be a series selector like 'up{job="prometheus"}'.
+// All samples in the range are returned, step is only sent as a hint to the server.
+// The connection settings of cfg apply like for MetricsWithConfig.
+func MetricsRemoteRead(ctx context.Context, endpoint, query string, queryTime time.Time, duration, step time.Duration, cfg MetricsConfig) (metrics model.Matrix, err error) {
+	defer wrap(ErrQuery, &err)
+	matchers, err := parseSelector(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", query, err)
+	}
+	rt, err := cfg.roundTripper()
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := queryTime.Add(-duration), queryTime
+	step = QueryStepWithMin(duration, int(step), cfg.Step, cfg.MinStep)
+	if cfg.AlignStep {
+		start, end = AlignTime(start, step), AlignTime(end, step)
+	}
+	body := encodeReadRequest(start, end, step, matchers)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(snappy.Encode(nil, body)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remote read request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Read-Version", remoteReadVersion)
+
+	resp, err := (&http.Client{Transport: rt, CheckRedirect: cfg.checkRedirect()}).Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("remote read exceeded the deadline: %w", err)
+		}
+		return nil, fmt.Errorf("failed to send remote read request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote read failed with %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	data, err = snappy.Decode(nil, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress remote read response: %w", err)
+	}
+	metrics, err = decodeReadResponse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode remote read response: %w", err)
+	}
+	return metrics, CheckEmpty(metrics)
+}
+
+// Types of label matchers in the remote read protocol
+type matchType uint64
+
+const (
+	matchEqual matchType = iota
+	matchNotEqual
+	matchRegexp
+	matchNotRegexp
+)
+
+var matchOperators = []struct {
+	op  string
+	typ matchType
+}{
+	// Two character operators first so they are not mistaken for "="
+	{"!=", matchNotEqual},
+	{"=~", matchRegexp},
+	{"!~", matchNotRegexp},
+	{"=", matchEqual},
+}
+
+type matcher struct {
+	typ   matchType
+	name  string
+	value string
+}
+
+// parseSelector parses a series selector like 'metric{label="value",other=~"re.*"}' into label matchers.
+// The metric name is optional if there are label matchers.
+// Label values must be quoted with double quotes or backticks.
+func parseSelector(s string) ([]matcher, error) {
+	var matchers []matcher
+	s = strings.TrimSpace(s)
+	name, s := consumeName(s)
+	if name != "" {
+		matchers = append(matchers, matcher{typ: matchEqual, name: model.MetricNameLabel, value: name})
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		if len(matchers) == 0 {
+			return nil, fmt.Errorf("empty selector")
+		}
+		return matchers, nil
+	}
+	if s[0] != '{' {
+		return nil, fmt.Errorf("unexpected %q, only series selectors are supported", s)
+	}
+	s = strings.TrimSpace(s[1:])
+	for !strings.HasPrefix(s, "}") {
+		var m matcher
+		m.name, s = consumeName(s)
+		if m.name == "" {
+			return nil, fmt.Errorf("expected label name at %q", s)
+		}
+		s = strings.TrimSpace(s)
+		op := ""
+		for _, o := range matchOperators {
+			if strings.HasPrefix(s, o.op) {
+				op, m.typ = o.op, o.typ
+				break
+			}
+		}
+		if op == "" {
+			return nil, fmt.Errorf("expected operator after label %s", m.name)
+		}
+		s = strings.TrimSpace(s[len(op):])
+		value, err := consumeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of label %s: %w", m.name, err)
+		}
+		m.value, err = strconv.Unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of label %s: %w", m.name, err)
+		}
+		matchers = append(matchers, m)
+		s = strings.TrimSpace(s[len(value):])
+		if strings.HasPrefix(s, ",") {
+			s = strings.TrimSpace(s[1:])
+		} else if !strings.HasPrefix(s, "}") {
+			return nil, fmt.Errorf("expected ',' or '}' at %q", s)
+		}
+	}
+	if rest := strings.TrimSpace(s[1:]); rest != "" {
+		return nil, fmt.Errorf("unexpected %q after selector", rest)
+	}
+	if len(matchers) == 0 {
+		return nil, fmt.Errorf("selector needs at least one matcher")
+	}
+	return matchers, nil
+}
+
+// consumeName returns the metric or label name at the start of s and the remainder.
+func consumeName(s string) (string, string) {
+	i := 0
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '_' || c == ':' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9' {
+			continue
+		}
+		break
+	}
+	return s[:i], s[i:]
+}
+
+// consumeString returns the quoted string literal at the start of s including its quotes.
+func consumeString(s string) (string, error) {
+	if s == "" || s[0] != '"' && s[0] != '`' {
+		return "", fmt.Errorf("expected quoted string at %q", s)
+	}
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == quote:
+			return s[:i+1], nil
+		}
+	}
+	return "", fmt.Errorf("unterminated string %s", s)
+}
+
+// encodeReadRequest creates a protobuf encoded prometheus.ReadRequest with a single query.
+func encodeReadRequest(start, end time.Time, step time.Duration, matchers []matcher) []byte {
+	startMs, endMs := start.UnixNano()/int64(time.Millisecond), end.UnixNano()/int64(time.Millisecond)
+
+	var query []byte
+	query = appendVarintField(query, 1, uint64(startMs))
+	query = appendVarintField(query, 2, uint64(endMs))
+	for _, m := range matchers {
+		var b []byte
+		b = appendVarintField(b, 1, uint64(m.typ))
+		b = appendStringField(b, 2, m.name)
+		b = appendStringField(b, 3, m.value)
+		query = appendBytesField(query, 3, b)
+	}
+	var hints []byte
+	hints = appendVarintField(hints, 1, uint64(step/time.Millisecond))
+	hints = appendVarintField(hints, 3, uint64(startMs))
+	hints = appendVarintField(hints, 4, uint64(endMs))
+	query = appendBytesField(query, 4, hints)
+
+	return appendBytesField(nil, 1, query)
+}
+
+func appendVarintField(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendStringField(b []byte, num protowire.Number, v string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendBytesField(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// decodeReadResponse converts a protobuf encoded prometheus.ReadResponse into a matrix.
+// Series of all query results are merged.
+func decodeReadResponse(data []byte) (model.Matrix, error) {
+	metrics := model.Matrix{}
+	// ReadResponse.results
+	err := protoFields(data, func(num protowire.Number, field []byte, _ uint64) error {
+		if num != 1 {
+			return nil
+		}
+		// QueryResult.timeseries
+		return protoFields(field, func(num protowire.Number, field []byte, _ uint64) error {
+			if num != 1 {
+				return nil
+			}
+			series, err := decodeTimeSeries(field)
+			if err != nil {
+				return err
+			}
+			metrics = append(metrics, series)
+			return nil
+		})
+	})
+	return metrics, err
+}
+
+func decodeTimeSeries(data []byte) (*model.SampleStream, error) {
+	series := &model.SampleStream{Metric: model.Metric{}}
+	err := protoFields(data, func(num protowire.Number, field []byte, _ uint64) error {
+		switch num {
+		// Label
+		case 1:
+			var name, value string
+			err := protoFields(field, func(num protowire.Number, field []byte, _ uint64) error {
+				switch num {
+				case 1:
+					name = string(field)
+				case 2:
+					value = string(field)
+				}
+				return nil
+			})
+			series.Metric[model.LabelName(name)] = model.LabelValue(value)
+			return err
+		// Sample
+		case 2:
+			var sample model.SamplePair
+			err := protoFields(field, func(num protowire.Number, _ []byte, v uint64) error {
+				switch num {
+				case 1:
+					sample.Value = model.SampleValue(math.Float64frombits(v))
+				case 2:
+					sample.Timestamp = model.Time(int64(v))
+				}
+				return nil
+			})
+			series.Values = append(series.Values, sample)
+			return err
+		}
+		return nil
+	})
+	return series, err
+}
+
+// protoFields calls fn for every field of the protobuf message in data.
+// Length-delimited fields are passed as bytes, varint and fixed size fields as number.
+func protoFields(data []byte, fn func(num protowire.Number, field []byte, v uint64) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		var field []byte
+		var v uint64
+		switch typ {
+		case protowire.BytesType:
+			field, n = protowire.ConsumeBytes(data)
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(data)
+		case protowire.Fixed32Type:
+			var v32 uint32
+			v32, n = protowire.ConsumeFixed32(data)
+			v = uint64(v32)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if err := fn(num, field, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
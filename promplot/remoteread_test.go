@@ -0,0 +1,179 @@
+package promplot
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		in       string
+		matchers []matcher
+		invalid  bool
+	}{
+		{in: "up", matchers: []matcher{{matchEqual, "__name__", "up"}}},
+		{in: " node:cpu:rate5m ", matchers: []matcher{{matchEqual, "__name__", "node:cpu:rate5m"}}},
+		{in: `up{job="node"}`, matchers: []matcher{{matchEqual, "__name__", "up"}, {matchEqual, "job", "node"}}},
+		{in: `up{}`, matchers: []matcher{{matchEqual, "__name__", "up"}}},
+		{
+			in:       "{ job != `a\\b` , instance=~\"host-\\\\d+\", env!~\"dev|test\", }",
+			matchers: []matcher{{matchNotEqual, "job", `a\b`}, {matchRegexp, "instance", `host-\d+`}, {matchNotRegexp, "env", "dev|test"}},
+		},
+		{in: `{name="a \"quoted\" value"}`, matchers: []matcher{{matchEqual, "name", `a "quoted" value`}}},
+		{in: "", invalid: true},
+		{in: "{}", invalid: true},
+		{in: "rate(up[5m])", invalid: true},
+		{in: "up{job}", invalid: true},
+		{in: `up{job="node"`, invalid: true},
+		{in: `up{job="node}`, invalid: true},
+		{in: `up{job='node'}`, invalid: true},
+		{in: `up{job="a" instance="b"}`, invalid: true},
+		{in: `up{job="a"} or down`, invalid: true},
+	}
+
+	for _, tt := range tests {
+		matchers, err := parseSelector(tt.in)
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("%q: parsing failed unexpectedly: %v", tt.in, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("%q: parsing should have failed", tt.in)
+			continue
+		}
+		if !reflect.DeepEqual(matchers, tt.matchers) {
+			t.Errorf(`
+%q
+Expected: %v
+Got       %v`, tt.in, tt.matchers, matchers)
+		}
+	}
+}
+
+func TestMetricsRemoteRead(t *testing.T) {
+	var start, end uint64
+	var matchers []matcher
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ := ioutil.ReadAll(r.Body)
+		body, err := snappy.Decode(nil, body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		protoFields(body, func(num protowire.Number, query []byte, _ uint64) error {
+			return protoFields(query, func(num protowire.Number, field []byte, v uint64) error {
+				switch num {
+				case 1:
+					start = v
+				case 2:
+					end = v
+				case 3:
+					var m matcher
+					protoFields(field, func(num protowire.Number, field []byte, v uint64) error {
+						switch num {
+						case 1:
+							m.typ = matchType(v)
+						case 2:
+							m.name = string(field)
+						case 3:
+							m.value = string(field)
+						}
+						return nil
+					})
+					matchers = append(matchers, m)
+				}
+				return nil
+			})
+		})
+
+		if len(matchers) > 1 && matchers[1].value == "none" {
+			w.Write(snappy.Encode(nil, appendBytesField(nil, 1, nil)))
+			return
+		}
+		var series []byte
+		series = appendBytesField(series, 1, appendStringField(appendStringField(nil, 1, "__name__"), 2, "up"))
+		series = appendBytesField(series, 1, appendStringField(appendStringField(nil, 1, "job"), 2, "node"))
+		for i, v := range []float64{1, 2} {
+			var sample []byte
+			sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(v))
+			sample = appendVarintField(sample, 2, uint64(1500000000000+int64(i)*60000))
+			series = appendBytesField(series, 2, sample)
+		}
+		w.Write(snappy.Encode(nil, appendBytesField(nil, 1, appendBytesField(nil, 1, series))))
+	}))
+	defer srv.Close()
+
+	metrics, err := MetricsRemoteRead(context.Background(), srv.URL, `up{job="node"}`, time.Unix(1500000060, 0), time.Minute, 1, MetricsConfig{Tenant: "team"})
+	if err != nil {
+		t.Fatalf("remote read failed unexpectedly: %v", err)
+	}
+	if header.Get("Content-Encoding") != "snappy" || header.Get("X-Prometheus-Remote-Read-Version") == "" {
+		t.Errorf("missing remote read headers: %v", header)
+	}
+	if header.Get(TenantHeader) != "team" {
+		t.Errorf("tenant header was not sent: %v", header)
+	}
+	if start != 1500000000000 || end != 1500000060000 {
+		t.Errorf("unexpected range: %d - %d", start, end)
+	}
+	expectedMatchers := []matcher{{matchEqual, "__name__", "up"}, {matchEqual, "job", "node"}}
+	if !reflect.DeepEqual(matchers, expectedMatchers) {
+		t.Errorf("unexpected matchers: %v", matchers)
+	}
+	expected := model.Matrix{{
+		Metric: model.Metric{"__name__": "up", "job": "node"},
+		Values: []model.SamplePair{{Timestamp: 1500000000000, Value: 1}, {Timestamp: 1500000060000, Value: 2}},
+	}}
+	if !reflect.DeepEqual(metrics, expected) {
+		t.Errorf(`
+Expected: %v
+Got       %v`, expected, metrics)
+	}
+
+	matchers = nil
+	_, err = MetricsRemoteRead(context.Background(), srv.URL, `up{job="none"}`, time.Unix(1500000060, 0), time.Minute, 1, MetricsConfig{})
+	if !errors.Is(err, ErrEmptyResult) {
+		t.Errorf("expected empty result, got: %v", err)
+	}
+
+	_, err = MetricsRemoteRead(context.Background(), srv.URL, "rate(up[5m])", time.Unix(1500000060, 0), time.Minute, 1, MetricsConfig{})
+	if !errors.Is(err, ErrQuery) {
+		t.Errorf("expected query error for invalid selector, got: %v", err)
+	}
+}
+
+func TestMetricsRemoteReadError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "remote read is disabled", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	_, err := MetricsRemoteRead(context.Background(), srv.URL, "up", time.Unix(1500000060, 0), time.Minute, 1, MetricsConfig{})
+	if err == nil {
+		t.Fatal("remote read should have failed")
+	}
+	if !errors.Is(err, ErrQuery) {
+		t.Errorf("expected query error, got: %v", err)
+	}
+	if expected := "remote read failed with 404 Not Found: remote read is disabled"; err.Error() != expected {
+		t.Errorf(`
+Expected: %q
+Got       %q`, expected, err.Error())
+	}
+}
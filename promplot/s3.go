@@ -0,0 +1,64 @@
+package promplot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// Custom S3 endpoint, only used in tests
+var s3Endpoint string
+
+// S3 uploads a plot to an object in an S3 bucket.
+// Credentials are loaded from the standard AWS credential chain like environment variables or ~/.aws/credentials.
+// An empty region uses the configured default region.
+// The Content-Type of the object is derived from the extension of key, e.g. image/png for plots/up.png.
+// The plot is streamed to S3 without buffering it completely.
+// Errors wrap ErrUpload.
+func S3(bucket, key, region string, plot io.WriterTo) error {
+	return S3Context(context.Background(), bucket, key, region, plot)
+}
+
+// S3Context uploads a plot to an S3 bucket like S3.
+// The upload is aborted when ctx is canceled or its deadline is exceeded.
+func S3Context(ctx context.Context, bucket, key, region string, plot io.WriterTo) (err error) {
+	defer wrap(ErrUpload, &err)
+	cfg := aws.NewConfig()
+	if region != "" {
+		cfg = cfg.WithRegion(region)
+	}
+	if s3Endpoint != "" {
+		cfg = cfg.WithEndpoint(s3Endpoint).WithS3ForcePathStyle(true)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{Config: *cfg, SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return fmt.Errorf("failed to create AWS session: %w", err)
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		_, err := plot.WriteTo(w)
+		w.CloseWithError(err)
+	}()
+	_, err = s3manager.NewUploader(sess).UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        r,
+		ContentType: aws.String(ContentType(strings.TrimPrefix(path.Ext(key), "."))),
+	})
+	// Stop writing if the upload failed early
+	r.Close()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("upload to s3://%s/%s exceeded the deadline: %w", bucket, key, err)
+		}
+		return fmt.Errorf("failed to upload to s3://%s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
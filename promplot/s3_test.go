@@ -0,0 +1,82 @@
+package promplot
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// setS3Credentials sets fake AWS credentials in the environment and returns a function restoring it.
+func setS3Credentials() (restore func()) {
+	var restores []func()
+	for k, v := range map[string]string{
+		"AWS_ACCESS_KEY_ID":           "key",
+		"AWS_SECRET_ACCESS_KEY":       "secret",
+		"AWS_SHARED_CREDENTIALS_FILE": "/nonexistent",
+		"AWS_CONFIG_FILE":             "/nonexistent",
+	} {
+		k := k
+		old, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		restores = append(restores, func() {
+			if ok {
+				os.Setenv(k, old)
+			} else {
+				os.Unsetenv(k)
+			}
+		})
+	}
+	return func() {
+		for _, r := range restores {
+			r()
+		}
+	}
+}
+
+func TestS3(t *testing.T) {
+	defer setS3Credentials()()
+
+	var method, path, contentType, body, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing/up.png" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchBucket</Code><Message>The specified bucket does not exist</Message></Error>`))
+			return
+		}
+		method, path, contentType, auth = r.Method, r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer srv.Close()
+	s3Endpoint = srv.URL
+	defer func() { s3Endpoint = "" }()
+
+	if err := S3("plots", "2017/up.png", "eu-west-1", bytes.NewBufferString("plot")); err != nil {
+		t.Fatalf("upload failed unexpectedly: %v", err)
+	}
+	if method != http.MethodPut || path != "/plots/2017/up.png" {
+		t.Errorf("unexpected request: %s %s", method, path)
+	}
+	if contentType != "image/png" {
+		t.Errorf("unexpected content type: %s", contentType)
+	}
+	if body != "plot" {
+		t.Errorf("unexpected body: %q", body)
+	}
+	if !strings.Contains(auth, "Credential=key/") || !strings.Contains(auth, "/eu-west-1/s3/") {
+		t.Errorf("request not signed with credentials from environment: %s", auth)
+	}
+
+	err := S3("missing", "up.png", "eu-west-1", bytes.NewBufferString("plot"))
+	if err == nil || !strings.Contains(err.Error(), "NoSuchBucket") {
+		t.Errorf("expected S3 error, got: %v", err)
+	}
+	if !errors.Is(err, ErrUpload) {
+		t.Errorf("expected upload error, got: %v", err)
+	}
+}
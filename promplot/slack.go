@@ -1,53 +1,370 @@
 package promplot
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"os"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/slack-go/slack"
 )
 
+// SlackConfig holds optional settings for posting to Slack.
+type SlackConfig struct {
+	// ThreadTimestamp of a message to post the file as a threaded reply to.
+	ThreadTimestamp string
+	// Filename of the uploaded file. Defaults to "promplot".
+	Filename string
+	// APIURL of the Slack API. Defaults to the official Slack API.
+	APIURL string
+	// Message is posted together with the file. Supports Slack mrkdwn formatting.
+	Message string
+	// Retries is the maximum number of retries for rate limited requests and server errors.
+	// Sharing an uploaded file in the channel is never retried to not post it twice.
+	Retries int
+	// Proxy is used for all requests instead of the proxy from HTTP_PROXY and HTTPS_PROXY, see ParseProxy.
+	Proxy *url.URL
+}
+
+// Initial wait time between retries. Doubled after each attempt.
+var slackBackoff = time.Second
+
 // Slack posts a file to a Slack channel.
+// Multiple channels can be given as a comma-separated list.
 func Slack(token, channel, title string, plot io.WriterTo) error {
-	api := slack.New(token)
+	return SlackWithConfig(token, channel, title, plot, SlackConfig{})
+}
+
+// SlackWithConfig posts a file to Slack channels using the given settings.
+// Multiple channels can be given as a comma-separated list.
+// The file is uploaded to all channels even if some of them fail, the error lists each failed channel.
+// Errors wrap ErrUpload.
+func SlackWithConfig(token, channel, title string, plot io.WriterTo, cfg SlackConfig) error {
+	return SlackContext(context.Background(), token, channel, title, plot, cfg)
+}
 
-	if _, _, err := api.PostMessageContext(context.Background(), channel, slack.MsgOptionPostMessageParameters(
-		slack.PostMessageParameters{
-			Username:  "Promplot",
-			IconEmoji: ":chart_with_upwards_trend:",
-		},
-	)); err != nil {
-		return fmt.Errorf("failed to post message: %v", err)
+// SlackContext posts a file to Slack channels like SlackWithConfig.
+// The upload is aborted when ctx is canceled or its deadline is exceeded, also while waiting to retry.
+// Channels not uploaded to by then are reported as failed.
+func SlackContext(ctx context.Context, token, channel, title string, plot io.WriterTo, cfg SlackConfig) (err error) {
+	defer wrap(ErrUpload, &err)
+	channels := SlackChannels(channel)
+	if len(channels) == 0 {
+		return fmt.Errorf("no channel given")
 	}
+	if cfg.Filename == "" {
+		cfg.Filename = "promplot"
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = slack.APIURL
+	}
+	client := slackHTTPClient(cfg.Proxy)
 
-	f, err := ioutil.TempFile("", "promplot-")
-	if err != nil {
-		return fmt.Errorf("failed to create tmp file: %v", err)
+	// The upload API needs to know the file size in advance
+	var buf bytes.Buffer
+	if _, err := plot.WriteTo(&buf); err != nil {
+		return fmt.Errorf("failed to write plot to buffer: %w", err)
 	}
 
-	defer func() {
-		if err = f.Close(); err != nil {
-			panic(fmt.Errorf("failed to close tmp file: %v", err))
+	// The upload API only accepts a single channel
+	var failed []string
+	var first error
+	for _, ch := range channels {
+		if err := uploadSlackFile(ctx, client, token, ch, title, buf.Bytes(), cfg); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				err = fmt.Errorf("slack upload exceeded the deadline: %w", err)
+			}
+			failed = append(failed, fmt.Sprintf("%s: %v", ch, err))
+			if first == nil {
+				first = err
+			}
 		}
-		if err = os.Remove(f.Name()); err != nil {
-			panic(fmt.Errorf("failed to delete tmp file: %v", err))
-		}
-	}()
+	}
+	switch {
+	case len(failed) == 0:
+		return nil
+	case len(channels) == 1:
+		return fmt.Errorf("failed to upload file: %w", first)
+	default:
+		return uploadError{msg: fmt.Sprintf("failed to upload file to %d of %d channels: %s", len(failed), len(channels), strings.Join(failed, "; ")), err: first}
+	}
+}
+
+// slackUpload is the response of the files.getUploadURLExternal method.
+type slackUpload struct {
+	UploadURL string `json:"upload_url"`
+	FileID    string `json:"file_id"`
+}
 
-	if _, err = plot.WriteTo(f); err != nil {
-		return fmt.Errorf("failed to write plot to file: %v", err)
+// uploadSlackFile uploads file to a single channel in the three steps of the Slack upload API.
+// Getting the upload URL and uploading the file are retried.
+// Completing the upload shares the file in the channel and is not retried,
+// because a request which failed after reaching Slack would post the file twice.
+func uploadSlackFile(ctx context.Context, client *http.Client, token, channel, title string, file []byte, cfg SlackConfig) error {
+	var upload slackUpload
+	if err := retry(ctx, cfg.Retries, func() error {
+		return slackMethod(ctx, client, token, cfg.APIURL+"files.getUploadURLExternal", url.Values{
+			"filename": {cfg.Filename},
+			"length":   {strconv.Itoa(len(file))},
+		}, &upload)
+	}); err != nil {
+		return err
 	}
 
-	if _, err = api.UploadFile(slack.FileUploadParameters{
-		Title:    title,
-		File:     f.Name(),
-		Channels: []string{channel},
+	if err := retry(ctx, cfg.Retries, func() error {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		w, err := mw.CreateFormFile("file", cfg.Filename)
+		if err != nil {
+			return fmt.Errorf("failed to create file field: %w", err)
+		}
+		if _, err := w.Write(file); err != nil {
+			return fmt.Errorf("failed to write plot to request: %w", err)
+		}
+		if err := mw.Close(); err != nil {
+			return fmt.Errorf("failed to close request body: %w", err)
+		}
+		_, err = slackPost(ctx, client, token, upload.UploadURL, mw.FormDataContentType(), &body)
+		return err
 	}); err != nil {
-		return fmt.Errorf("failed to upload file: %v", err)
+		return err
+	}
+
+	files, err := json.Marshal([]slack.FileSummary{{ID: upload.FileID, Title: title}})
+	if err != nil {
+		return fmt.Errorf("failed to encode file: %w", err)
+	}
+	values := url.Values{
+		"files":      {string(files)},
+		"channel_id": {channel},
 	}
+	if cfg.Message != "" {
+		values.Set("initial_comment", cfg.Message)
+	}
+	if cfg.ThreadTimestamp != "" {
+		values.Set("thread_ts", cfg.ThreadTimestamp)
+	}
+	return slackMethod(ctx, client, token, cfg.APIURL+"files.completeUploadExternal", values, nil)
+}
+
+// slackMethod calls a method of the Slack API and decodes the response into result if it is not nil.
+// Responses which are not ok are returned as slack.SlackErrorResponse.
+func slackMethod(ctx context.Context, client *http.Client, token, endpoint string, values url.Values, result interface{}) error {
+	data, err := slackPost(ctx, client, token, endpoint, "application/x-www-form-urlencoded", strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	var res slack.SlackResponse
+	if err := json.Unmarshal(data, &res); err != nil {
+		return fmt.Errorf("failed to decode slack response: %w", err)
+	}
+	if !res.Ok {
+		return slack.SlackErrorResponse{Err: res.Error, ResponseMetadata: res.ResponseMetadata}
+	}
+	if result != nil {
+		if err := json.Unmarshal(data, result); err != nil {
+			return fmt.Errorf("failed to decode slack response: %w", err)
+		}
+	}
+	return nil
+}
+
+// slackPost sends an authenticated request to Slack and returns the response body.
+// Status codes are reported with the error types of the slack package, so that retry can tell which requests to repeat.
+func slackPost(ctx context.Context, client *http.Client, token, endpoint, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusTooManyRequests {
+		// Retry-After is given in seconds
+		seconds, _ := strconv.Atoi(res.Header.Get("Retry-After"))
+		return nil, &slack.RateLimitedError{RetryAfter: time.Duration(seconds) * time.Second}
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, slack.StatusCodeError{Code: res.StatusCode, Status: res.Status}
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read slack response: %w", err)
+	}
+	return data, nil
+}
 
+// CheckSlack checks that token is valid using the auth.test method of the Slack API.
+// Only APIURL, Proxy and Retries of cfg are used.
+// Errors wrap ErrUpload.
+func CheckSlack(token string, cfg SlackConfig) (err error) {
+	defer wrap(ErrUpload, &err)
+	return checkToken(context.Background(), newSlackClient(token, cfg.APIURL, cfg.Proxy), cfg.Retries)
+}
+
+func checkToken(ctx context.Context, api *slack.Client, retries int) error {
+	if err := retry(ctx, retries, func() error {
+		_, err := api.AuthTestContext(ctx)
+		return err
+	}); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("slack token check exceeded the deadline: %w", err)
+		}
+		return fmt.Errorf("failed to check token: %w", err)
+	}
 	return nil
 }
+
+// slackID matches IDs of public and private channels and direct messages.
+// Channel names are always lower case.
+var slackID = regexp.MustCompile(`^[CGD][A-Z0-9]{8,}$`)
+
+// ResolveSlackChannels checks token like CheckSlack and returns the IDs of a comma-separated list of channels.
+// Names can be given with or without a leading # and are looked up with the conversations.list method,
+// which requires the channels:read scope, and groups:read for private channels.
+// IDs are kept as they are. Calling it before querying Prometheus detects a wrong Slack setup early.
+// Only APIURL, Proxy and Retries of cfg are used. The requests are aborted when ctx is done.
+// Errors wrap ErrUpload.
+func ResolveSlackChannels(ctx context.Context, token, channel string, cfg SlackConfig) (ids []string, err error) {
+	defer wrap(ErrUpload, &err)
+	channels := SlackChannels(channel)
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("no channel given")
+	}
+	api := newSlackClient(token, cfg.APIURL, cfg.Proxy)
+	if err := checkToken(ctx, api, cfg.Retries); err != nil {
+		return nil, err
+	}
+
+	names := map[string]string{}
+	for _, ch := range channels {
+		if !slackID.MatchString(ch) {
+			names[strings.TrimPrefix(ch, "#")] = ""
+		}
+	}
+	// Page through all channels only if there are names to resolve
+	params := &slack.GetConversationsParameters{Types: []string{"public_channel", "private_channel"}, ExcludeArchived: true, Limit: 1000}
+	for missing := len(names); missing > 0; {
+		var page []slack.Channel
+		var next string
+		if err := retry(ctx, cfg.Retries, func() error {
+			var err error
+			page, next, err = api.GetConversationsContext(ctx, params)
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("failed to list channels: %w", err)
+		}
+		for _, c := range page {
+			if id, ok := names[c.Name]; ok && id == "" {
+				names[c.Name] = c.ID
+				missing--
+			}
+		}
+		if next == "" {
+			break
+		}
+		params.Cursor = next
+	}
+
+	var unknown []string
+	for _, ch := range channels {
+		if slackID.MatchString(ch) {
+			ids = append(ids, ch)
+			continue
+		}
+		id := names[strings.TrimPrefix(ch, "#")]
+		if id == "" {
+			unknown = append(unknown, ch)
+		}
+		ids = append(ids, id)
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown channel %s, the bot needs to be invited to private channels", strings.Join(unknown, ", "))
+	}
+	return ids, nil
+}
+
+// SlackChannels splits a comma-separated list of channels and drops empty entries.
+func SlackChannels(list string) []string {
+	var channels []string
+	for _, ch := range strings.Split(list, ",") {
+		if ch = strings.TrimSpace(ch); ch != "" {
+			channels = append(channels, ch)
+		}
+	}
+	return channels
+}
+
+// uploadError describes failed uploads while still wrapping the first underlying error.
+type uploadError struct {
+	msg string
+	err error
+}
+
+func (e uploadError) Error() string { return e.msg }
+
+func (e uploadError) Unwrap() error { return e.err }
+
+func newSlackClient(token, apiURL string, proxy *url.URL) *slack.Client {
+	var opts []slack.Option
+	if apiURL != "" {
+		opts = append(opts, slack.OptionAPIURL(apiURL))
+	}
+	if proxy != nil {
+		opts = append(opts, slack.OptionHTTPClient(slackHTTPClient(proxy)))
+	}
+	return slack.New(token, opts...)
+}
+
+// slackHTTPClient returns the client for requests to Slack, using proxy if it is set.
+func slackHTTPClient(proxy *url.URL) *http.Client {
+	if proxy == nil {
+		return http.DefaultClient
+	}
+	return &http.Client{Transport: proxyTransport(proxy)}
+}
+
+// retry calls fn until it succeeds, fails with a permanent error or no retries are left.
+// It waits with exponential backoff in between or as long as Slack asks for on rate limits.
+// Waiting stops with the error of the last attempt when ctx is done.
+func retry(ctx context.Context, retries int, fn func() error) error {
+	wait := slackBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retries || !retryable(err) {
+			return err
+		}
+		delay := wait
+		var rateLimit *slack.RateLimitedError
+		if errors.As(err, &rateLimit) && rateLimit.RetryAfter > 0 {
+			delay = rateLimit.RetryAfter
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w, stopped retrying: %v", err, ctx.Err())
+		}
+		wait *= 2
+	}
+}
+
+func retryable(err error) bool {
+	var r interface{ Retryable() bool }
+	return errors.As(err, &r) && r.Retryable()
+}
@@ -0,0 +1,310 @@
+package promplot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slackServer mocks the Slack API and records the called methods and the posted message.
+func slackServer(calls *[]string, message *string) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls = append(*calls, r.URL.Path)
+		if m := r.FormValue("initial_comment"); m != "" {
+			*message = m
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/files.getUploadURLExternal":
+			w.Write([]byte(`{"ok":true,"upload_url":"` + srv.URL + `/upload","file_id":"F1"}`))
+		case "/upload":
+			w.Write([]byte(`OK`))
+		case "/files.completeUploadExternal":
+			w.Write([]byte(`{"ok":true,"files":[{"id":"F1","title":"title"}]}`))
+		default:
+			w.Write([]byte(`{"ok":false,"error":"unknown_method"}`))
+		}
+	}))
+	return srv
+}
+
+func TestSlack(t *testing.T) {
+	upload := []string{"/files.getUploadURLExternal", "/upload", "/files.completeUploadExternal"}
+	tests := []struct {
+		thread  string
+		message string
+		calls   []string
+	}{
+		{calls: upload},
+		{thread: "1.1", calls: upload},
+		{message: "*Nightly* latency report", calls: upload},
+	}
+
+	for i, tt := range tests {
+		var calls []string
+		var message string
+		srv := slackServer(&calls, &message)
+		err := SlackWithConfig("token", "C1", "title", bytes.NewBufferString("plot"), SlackConfig{
+			ThreadTimestamp: tt.thread,
+			Message:         tt.message,
+			APIURL:          srv.URL + "/",
+		})
+		srv.Close()
+		if err != nil {
+			t.Errorf("%d. upload failed unexpectedly: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(calls, tt.calls) {
+			t.Errorf(`
+%d.
+Expected: %v
+Got       %v`, i, tt.calls, calls)
+		}
+		if message != tt.message {
+			t.Errorf("%d. expected message %q, got %q", i, tt.message, message)
+		}
+	}
+}
+
+func TestSlackRetry(t *testing.T) {
+	slackBackoff = time.Millisecond
+	defer func() { slackBackoff = time.Second }()
+
+	tests := []struct {
+		status  int
+		body    string
+		retries int
+		calls   int
+		invalid bool
+	}{
+		{status: http.StatusTooManyRequests, retries: 3, calls: 3},
+		{status: http.StatusBadGateway, retries: 3, calls: 3},
+		{status: http.StatusBadGateway, retries: 1, calls: 2, invalid: true},
+		{status: http.StatusOK, body: `{"ok":false,"error":"invalid_auth"}`, retries: 3, calls: 1, invalid: true},
+	}
+
+	for i, tt := range tests {
+		calls := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Content-Type", "application/json")
+			// Fail the first two calls
+			if calls <= 2 || tt.body != "" {
+				if tt.status == http.StatusTooManyRequests {
+					w.Header().Set("Retry-After", "0")
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+				return
+			}
+			w.Write([]byte(`{"ok":true,"channel":"C1","ts":"1.1"}`))
+		}))
+		err := retry(context.Background(), tt.retries, func() error {
+			_, _, err := newSlackClient("token", srv.URL+"/", nil).PostMessage("C1")
+			return err
+		})
+		srv.Close()
+		if err != nil && !tt.invalid {
+			t.Errorf("%d. request failed unexpectedly: %v", i, err)
+		}
+		if err == nil && tt.invalid {
+			t.Errorf("%d. request should have failed", i)
+		}
+		if calls != tt.calls {
+			t.Errorf("%d. expected %d calls, got %d", i, tt.calls, calls)
+		}
+	}
+}
+
+func TestSlackUploadRetry(t *testing.T) {
+	slackBackoff = time.Millisecond
+	defer func() { slackBackoff = time.Second }()
+
+	// The first call of every step fails with a server error
+	var calls []string
+	failed := map[string]bool{}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.URL.Path)
+		if !failed[r.URL.Path] {
+			failed[r.URL.Path] = true
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/files.getUploadURLExternal":
+			w.Write([]byte(`{"ok":true,"upload_url":"` + srv.URL + `/upload","file_id":"F1"}`))
+		case "/upload":
+			w.Write([]byte(`OK`))
+		case "/files.completeUploadExternal":
+			w.Write([]byte(`{"ok":true,"files":[{"id":"F1","title":"title"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	err := SlackWithConfig("token", "C1", "title", bytes.NewBufferString("plot"), SlackConfig{APIURL: srv.URL + "/", Retries: 3})
+	if err == nil || !errors.Is(err, ErrUpload) {
+		t.Errorf("expected upload error, got: %v", err)
+	}
+	// Completing the upload might already have shared the file
+	expected := []string{
+		"/files.getUploadURLExternal", "/files.getUploadURLExternal",
+		"/upload", "/upload",
+		"/files.completeUploadExternal",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf(`
+Expected: %v
+Got       %v`, expected, calls)
+	}
+}
+
+func TestSlackDeadline(t *testing.T) {
+	// Slack asks to wait much longer than the deadline
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := SlackContext(ctx, "token", "C1", "title", bytes.NewBufferString("plot"), SlackConfig{APIURL: srv.URL + "/", Retries: 3})
+	if err == nil || !strings.Contains(err.Error(), "exceeded the deadline") || !errors.Is(err, ErrUpload) {
+		t.Errorf("expected upload error about the deadline, got: %v", err)
+	}
+	if took := time.Since(start); took > 5*time.Second {
+		t.Errorf("upload should stop at the deadline, took %v", took)
+	}
+}
+
+func TestSlackChannels(t *testing.T) {
+	var channels []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/files.getUploadURLExternal":
+			w.Write([]byte(`{"ok":true,"upload_url":"http://` + r.Host + `/upload","file_id":"F1"}`))
+		case "/upload":
+			w.Write([]byte(`OK`))
+		case "/files.completeUploadExternal":
+			ch := r.FormValue("channel_id")
+			channels = append(channels, ch)
+			if ch == "broken" {
+				w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+				return
+			}
+			w.Write([]byte(`{"ok":true,"files":[{"id":"F1","title":"title"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		channel  string
+		channels []string
+		failed   string
+	}{
+		{channel: "C1", channels: []string{"C1"}},
+		{channel: "C1, C2,,", channels: []string{"C1", "C2"}},
+		{channel: "C1,broken,C2", channels: []string{"C1", "broken", "C2"}, failed: "1 of 3 channels: broken: channel_not_found"},
+		{channel: "broken", channels: []string{"broken"}, failed: "channel_not_found"},
+		{channel: " , ", failed: "no channel"},
+	}
+
+	for i, tt := range tests {
+		channels = nil
+		err := SlackWithConfig("token", tt.channel, "title", bytes.NewBufferString("plot"), SlackConfig{APIURL: srv.URL + "/"})
+		if tt.failed == "" && err != nil {
+			t.Errorf("%d. upload failed unexpectedly: %v", i, err)
+		}
+		if tt.failed != "" && (err == nil || !strings.Contains(err.Error(), tt.failed)) {
+			t.Errorf("%d. expected error containing %q, got: %v", i, tt.failed, err)
+		}
+		if err != nil && !errors.Is(err, ErrUpload) {
+			t.Errorf("%d. error should wrap ErrUpload: %v", i, err)
+		}
+		if !reflect.DeepEqual(channels, tt.channels) {
+			t.Errorf("%d. expected uploads to %v, got %v", i, tt.channels, channels)
+		}
+	}
+}
+
+func TestCheckSlack(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/auth.test" || r.FormValue("token") != "valid" {
+			w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"user":"promplot","team":"Team"}`))
+	}))
+	defer srv.Close()
+
+	if err := CheckSlack("valid", SlackConfig{APIURL: srv.URL + "/"}); err != nil {
+		t.Errorf("check failed unexpectedly: %v", err)
+	}
+	err := CheckSlack("invalid", SlackConfig{APIURL: srv.URL + "/"})
+	if err == nil || !strings.Contains(err.Error(), "invalid_auth") || !errors.Is(err, ErrUpload) {
+		t.Errorf("expected upload error containing invalid_auth, got: %v", err)
+	}
+}
+
+func TestResolveSlackChannels(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.URL.Path+r.FormValue("cursor"))
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.FormValue("token") != "valid":
+			w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`))
+		case r.URL.Path == "/auth.test":
+			w.Write([]byte(`{"ok":true,"user":"promplot","team":"Team"}`))
+		case r.URL.Path == "/conversations.list" && r.FormValue("cursor") == "":
+			w.Write([]byte(`{"ok":true,"channels":[{"id":"C00000001","name":"general"}],"response_metadata":{"next_cursor":"2"}}`))
+		case r.URL.Path == "/conversations.list":
+			w.Write([]byte(`{"ok":true,"channels":[{"id":"G00000002","name":"ops"}],"response_metadata":{"next_cursor":""}}`))
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		token   string
+		channel string
+		ids     []string
+		calls   []string
+		err     string
+	}{
+		{token: "valid", channel: "C00000009", ids: []string{"C00000009"}, calls: []string{"/auth.test"}},
+		{token: "valid", channel: "general", ids: []string{"C00000001"}, calls: []string{"/auth.test", "/conversations.list"}},
+		{token: "valid", channel: "#ops, general,D00000003", ids: []string{"G00000002", "C00000001", "D00000003"}, calls: []string{"/auth.test", "/conversations.list", "/conversations.list2"}},
+		{token: "valid", channel: "general,missing", calls: []string{"/auth.test", "/conversations.list", "/conversations.list2"}, err: "unknown channel missing"},
+		{token: "invalid", channel: "general", calls: []string{"/auth.test"}, err: "invalid_auth"},
+		{token: "valid", channel: ",", err: "no channel"},
+	}
+
+	for i, tt := range tests {
+		calls = nil
+		ids, err := ResolveSlackChannels(context.Background(), tt.token, tt.channel, SlackConfig{APIURL: srv.URL + "/"})
+		if tt.err == "" && err != nil {
+			t.Errorf("%d. resolving failed unexpectedly: %v", i, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err) || !errors.Is(err, ErrUpload)) {
+			t.Errorf("%d. expected upload error containing %q, got: %v", i, tt.err, err)
+		}
+		if !reflect.DeepEqual(ids, tt.ids) {
+			t.Errorf("%d. expected IDs %v, got %v", i, tt.ids, ids)
+		}
+		if !reflect.DeepEqual(calls, tt.calls) {
+			t.Errorf("%d. expected calls %v, got %v", i, tt.calls, calls)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package promplot
+
+import (
+	"math"
+	"time"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+// SmoothPoints applies a centered moving average over window points.
+// At the edges the average is taken over the available points only.
+// NaN and infinite values are kept as gaps and left out of the averages.
+func SmoothPoints(data plotter.XYs, window int) plotter.XYs {
+	smoothed := make(plotter.XYs, len(data))
+	if window < 1 {
+		window = 1
+	}
+	before := (window - 1) / 2
+	after := window - 1 - before
+	for i := range data {
+		start, end := i-before, i+after+1
+		if start < 0 {
+			start = 0
+		}
+		if end > len(data) {
+			end = len(data)
+		}
+		smoothed[i] = plotter.XY{X: data[i].X, Y: smoothValue(data, i, start, end)}
+	}
+	return smoothed
+}
+
+// SmoothDuration applies a centered moving average over all points within the time window.
+// X values are expected to be Unix timestamps in seconds and sorted.
+// Gaps are handled like in SmoothPoints.
+func SmoothDuration(data plotter.XYs, window time.Duration) plotter.XYs {
+	smoothed := make(plotter.XYs, len(data))
+	half := window.Seconds() / 2
+	start, end := 0, 0
+	for i := range data {
+		for data[start].X < data[i].X-half {
+			start++
+		}
+		for end < len(data) && data[end].X <= data[i].X+half {
+			end++
+		}
+		smoothed[i] = plotter.XY{X: data[i].X, Y: smoothValue(data, i, start, end)}
+	}
+	return smoothed
+}
+
+// smoothValue returns the mean of data[start:end] or the value at i itself if it is a gap.
+func smoothValue(data plotter.XYs, i, start, end int) float64 {
+	if !finite(data[i].Y) {
+		return data[i].Y
+	}
+	return mean(data[start:end])
+}
+
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// mean returns the average of all finite values.
+func mean(data plotter.XYs) float64 {
+	var sum float64
+	n := 0
+	for _, p := range data {
+		if finite(p.Y) {
+			sum += p.Y
+			n++
+		}
+	}
+	return sum / float64(n)
+}
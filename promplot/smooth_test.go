@@ -0,0 +1,87 @@
+package promplot
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+func TestSmoothPoints(t *testing.T) {
+	data := plotter.XYs{{X: 0, Y: 1}, {X: 1, Y: 2}, {X: 2, Y: 3}, {X: 3, Y: 4}, {X: 4, Y: 5}}
+	tests := []struct {
+		window   int
+		smoothed []float64
+	}{
+		{window: 0, smoothed: []float64{1, 2, 3, 4, 5}},
+		{window: 1, smoothed: []float64{1, 2, 3, 4, 5}},
+		{window: 2, smoothed: []float64{1.5, 2.5, 3.5, 4.5, 5}},
+		{window: 3, smoothed: []float64{1.5, 2, 3, 4, 4.5}},
+		{window: 10, smoothed: []float64{3, 3, 3, 3, 3}},
+	}
+
+	for i, tt := range tests {
+		var ys []float64
+		for j, p := range SmoothPoints(data, tt.window) {
+			if p.X != data[j].X {
+				t.Errorf("%d. x values should be kept", i)
+			}
+			ys = append(ys, p.Y)
+		}
+		if !reflect.DeepEqual(ys, tt.smoothed) {
+			t.Errorf(`
+%d.
+Window:   %d
+Expected: %v
+Got       %v`, i, tt.window, tt.smoothed, ys)
+		}
+	}
+}
+
+func TestSmoothDuration(t *testing.T) {
+	// Irregular samples, the window should be based on time not index
+	data := plotter.XYs{{X: 0, Y: 1}, {X: 60, Y: 3}, {X: 70, Y: 5}, {X: 200, Y: 7}}
+	tests := []struct {
+		window   time.Duration
+		smoothed []float64
+	}{
+		{window: 0, smoothed: []float64{1, 3, 5, 7}},
+		{window: 30 * time.Second, smoothed: []float64{1, 4, 4, 7}},
+		{window: 2 * time.Minute, smoothed: []float64{2, 3, 4, 7}},
+		{window: time.Hour, smoothed: []float64{4, 4, 4, 4}},
+	}
+
+	for i, tt := range tests {
+		var ys []float64
+		for _, p := range SmoothDuration(data, tt.window) {
+			ys = append(ys, p.Y)
+		}
+		if !reflect.DeepEqual(ys, tt.smoothed) {
+			t.Errorf(`
+%d.
+Window:   %v
+Expected: %v
+Got       %v`, i, tt.window, tt.smoothed, ys)
+		}
+	}
+}
+
+func TestSmoothGaps(t *testing.T) {
+	nan := math.NaN()
+	data := plotter.XYs{{X: 0, Y: 1}, {X: 1, Y: nan}, {X: 2, Y: 3}, {X: 3, Y: math.Inf(1)}, {X: 4, Y: 5}}
+	expected := []float64{1, nan, 3, math.Inf(1), 5}
+
+	for name, smoothed := range map[string]plotter.XYs{
+		"points":   SmoothPoints(data, 3),
+		"duration": SmoothDuration(data, 2*time.Second),
+	} {
+		for i, p := range smoothed {
+			e := expected[i]
+			if math.IsNaN(e) && !math.IsNaN(p.Y) || !math.IsNaN(e) && p.Y != e {
+				t.Errorf("%s: point %d: expected %v, got %v", name, i, e, p.Y)
+			}
+		}
+	}
+}
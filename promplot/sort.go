@@ -0,0 +1,113 @@
+package promplot
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/common/model"
+)
+
+// Aggregates are the values series can be sorted by.
+var Aggregates = []string{"max", "min", "last", "avg"}
+
+// sortSeries returns a copy of metrics sorted by their labels.
+// This keeps colors and legend entries stable between runs.
+// Series with the same labels keep their order.
+func sortSeries(metrics model.Matrix) model.Matrix {
+	sorted := make(model.Matrix, len(metrics))
+	copy(sorted, metrics)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Metric.String() < sorted[j].Metric.String()
+	})
+	return sorted
+}
+
+// SortBy returns a copy of metrics sorted descending by an aggregate of their values.
+// The aggregate is one of Aggregates. NaN values are ignored.
+// Series without any values are sorted last, ties are ordered by labels.
+func SortBy(metrics model.Matrix, by string) (model.Matrix, error) {
+	if err := validAggregate(by); err != nil {
+		return nil, err
+	}
+	sorted := sortSeries(metrics)
+	values := make(map[*model.SampleStream]float64, len(sorted))
+	for _, sample := range sorted {
+		values[sample] = aggregate(sample.Values, by)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := values[sorted[i]], values[sorted[j]]
+		if math.IsNaN(b) {
+			return !math.IsNaN(a)
+		}
+		return a > b
+	})
+	return sorted, nil
+}
+
+// aggregate calculates an aggregate of all values which are not NaN.
+// Returns NaN if there are no such values.
+func aggregate(values []model.SamplePair, by string) float64 {
+	result := math.NaN()
+	n := 0
+	for _, v := range values {
+		f := float64(v.Value)
+		if math.IsNaN(f) {
+			continue
+		}
+		n++
+		switch {
+		case n == 1:
+			result = f
+		case by == "max":
+			result = math.Max(result, f)
+		case by == "min":
+			result = math.Min(result, f)
+		case by == "last":
+			result = f
+		case by == "avg":
+			result += f
+		}
+	}
+	if by == "avg" && n > 0 {
+		result /= float64(n)
+	}
+	return result
+}
+
+func validAggregate(by string) error {
+	for _, a := range Aggregates {
+		if by == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown aggregate %q, valid aggregates are: %s", by, strings.Join(Aggregates, ", "))
+}
+
+// TopN keeps the n series with the highest aggregate of their values, see SortBy.
+// If other is set, the remaining series are summed up into a single additional series
+// with the label "other" set to the number of summed series.
+// Series are returned in descending order of the aggregate, followed by the other series.
+func TopN(metrics model.Matrix, n int, by string, other bool) (model.Matrix, error) {
+	sorted, err := SortBy(metrics, by)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("number of series cannot be negative")
+	}
+	if len(sorted) <= n {
+		return sorted, nil
+	}
+	top, rest := sorted[:n], sorted[n:]
+	if !other {
+		return top, nil
+	}
+	return append(top, sumSeries(rest, model.Metric{"other": model.LabelValue(fmt.Sprintf("%d series", len(rest)))})), nil
+}
+
+// sumSeries adds up the values of all series at each timestamp. NaN values are ignored.
+func sumSeries(metrics model.Matrix, metric model.Metric) *model.SampleStream {
+	return combineSeries(metrics, metric, "sum")
+}
@@ -0,0 +1,120 @@
+package promplot
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestSortSeries(t *testing.T) {
+	a := &model.SampleStream{Metric: model.Metric{"job": "a"}}
+	b := &model.SampleStream{Metric: model.Metric{"job": "b"}}
+	b2 := &model.SampleStream{Metric: model.Metric{"job": "b"}}
+	c := &model.SampleStream{Metric: model.Metric{"instance": "x", "job": "a"}}
+	expected := model.Matrix{c, a, b, b2}
+
+	tests := []model.Matrix{
+		{a, b, b2, c},
+		{b, c, b2, a},
+		{c, b, a, b2},
+	}
+
+	for i, input := range tests {
+		sorted := sortSeries(input)
+		for j := range expected {
+			if sorted[j] != expected[j] {
+				t.Errorf("%d. expected %v at %d, got %v", i, expected[j].Metric, j, sorted[j].Metric)
+			}
+		}
+	}
+}
+
+func TestSortBy(t *testing.T) {
+	nan := math.NaN()
+	metrics := model.Matrix{
+		testSeries(model.Metric{"job": "a"}, 1, 10, 1),
+		testSeries(model.Metric{"job": "b"}, 5, 5, 5),
+		testSeries(model.Metric{"job": "c"}, nan, nan),
+		testSeries(model.Metric{"job": "d"}, 0, 2, nan, 8),
+		testSeries(model.Metric{"job": "e"}),
+	}
+
+	tests := []struct {
+		by       string
+		expected string
+		invalid  bool
+	}{
+		{by: "max", expected: "a d b c e"},
+		{by: "min", expected: "b a d c e"},
+		{by: "last", expected: "d b a c e"},
+		{by: "avg", expected: "b a d c e"},
+		{by: "median", invalid: true},
+	}
+
+	for _, tt := range tests {
+		sorted, err := SortBy(metrics, tt.by)
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("sorting by %s failed unexpectedly: %v", tt.by, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("sorting by %s should have failed", tt.by)
+			continue
+		}
+		var jobs []string
+		for _, s := range sorted {
+			jobs = append(jobs, string(s.Metric["job"]))
+		}
+		if got := strings.Join(jobs, " "); got != tt.expected {
+			t.Errorf("sorting by %s: expected %s, got %s", tt.by, tt.expected, got)
+		}
+	}
+}
+
+func TestTopN(t *testing.T) {
+	metrics := model.Matrix{
+		testSeries(model.Metric{"job": "a"}, 1, 2),
+		testSeries(model.Metric{"job": "b"}, 5, 6),
+		testSeries(model.Metric{"job": "c"}, 3, math.NaN()),
+		testSeries(model.Metric{"job": "d"}, 4, 1),
+	}
+
+	top, err := TopN(metrics, 2, "max", false)
+	if err != nil {
+		t.Fatalf("top failed unexpectedly: %v", err)
+	}
+	if len(top) != 2 || top[0].Metric["job"] != "b" || top[1].Metric["job"] != "d" {
+		t.Errorf("unexpected top series: %v", top)
+	}
+
+	top, err = TopN(metrics, 2, "max", true)
+	if err != nil {
+		t.Fatalf("top failed unexpectedly: %v", err)
+	}
+	if len(top) != 3 {
+		t.Fatalf("expected 3 series, got %d", len(top))
+	}
+	other := top[2]
+	if other.Metric["other"] != "2 series" {
+		t.Errorf("unexpected other labels: %v", other.Metric)
+	}
+	if len(other.Values) != 2 || other.Values[0].Value != 4 || other.Values[1].Value != 2 {
+		t.Errorf("unexpected other values: %v", other.Values)
+	}
+
+	top, err = TopN(metrics, 10, "max", true)
+	if err != nil {
+		t.Fatalf("top failed unexpectedly: %v", err)
+	}
+	if len(top) != 4 {
+		t.Errorf("expected all 4 series, got %d", len(top))
+	}
+
+	if _, err := TopN(metrics, -1, "max", false); err == nil {
+		t.Error("negative top should have failed")
+	}
+}
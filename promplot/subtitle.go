@@ -0,0 +1,97 @@
+package promplot
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// Space between title and subtitle
+const subtitleGap = 2 * vg.Millimeter
+
+// Longer subtitles are truncated
+const subtitleMaxLines = 3
+
+// drawWithSubtitle draws p onto c with the subtitle in the style of the legend between the title and the data.
+// The subtitle is wrapped to the width of c and aligned like align.
+func drawWithSubtitle(c draw.Canvas, p *plot.Plot, subtitle, align string) {
+	sty := p.Legend.TextStyle
+	var x vg.Length
+	sty.XAlign, x = textAnchor(c, align)
+	sty.YAlign = draw.YTop
+	text := strings.Join(wrapText(subtitle, sty.Font, c.Max.X-c.Min.X, subtitleMaxLines), "\n")
+	height := sty.Height(text)
+
+	top := c.Max.Y
+	area := c
+	if p.Title.Text != "" {
+		_, h, d := p.Title.Handler.Box(p.Title.Text, p.Title.Font)
+		top -= h + d + subtitleGap
+		// Move the data down to make room
+		p.Title.Padding += subtitleGap + height
+	} else {
+		area.Max.Y -= height + p.Title.Padding
+	}
+	// Draw the subtitle last since the plot fills its background
+	p.Draw(area)
+	c.FillText(sty, vg.Point{X: x, Y: top}, text)
+}
+
+// wrapText breaks text into lines which are not wider than width.
+// Lines are broken between words and within words which do not fit into a line on their own.
+// Text exceeding maxLines is cut off and marked with "...".
+func wrapText(text string, font vg.Font, width vg.Length, maxLines int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if font.Width(candidate) <= width {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		// Break words which are too long on their own
+		for font.Width(word) > width {
+			n := fitting(word, font, width)
+			lines = append(lines, word[:n])
+			word = word[n:]
+		}
+		line = word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	if len(lines) <= maxLines {
+		return lines
+	}
+	lines = lines[:maxLines]
+	last := lines[maxLines-1]
+	for last != "" && font.Width(last+"...") > width {
+		_, size := utf8.DecodeLastRuneInString(last)
+		last = last[:len(last)-size]
+	}
+	lines[maxLines-1] = last + "..."
+	return lines
+}
+
+// fitting returns the length in bytes of the longest prefix of s which fits into width.
+// At least one character is returned so that wrapping always makes progress.
+func fitting(s string, font vg.Font, width vg.Length) int {
+	n := 0
+	for i, r := range s {
+		end := i + utf8.RuneLen(r)
+		if n > 0 && font.Width(s[:end]) > width {
+			break
+		}
+		n = end
+	}
+	return n
+}
@@ -0,0 +1,54 @@
+package promplot
+
+import (
+	"reflect"
+	"testing"
+
+	"gonum.org/v1/plot/vg"
+)
+
+func TestWrapText(t *testing.T) {
+	font, err := vg.MakeFont(DefaultFont, DefaultTickFontSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Width of ten characters
+	width := font.Width("0123456789")
+
+	tests := []struct {
+		in    string
+		lines []string
+	}{
+		{in: "", lines: nil},
+		{in: "up", lines: []string{"up"}},
+		{in: "  rate(up[5m])  ", lines: []string{"rate(up[5m])"}},
+		{in: "0123 5678 0123 5678", lines: []string{"0123 5678", "0123 5678"}},
+		{in: "01234567890123456789", lines: []string{"0123456789", "0123456789"}},
+		{in: "0123 0123456789012", lines: []string{"0123", "0123456789", "012"}},
+		{in: "0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1", lines: []string{"0 1 2 3 4 5 6", "7 8 9 0 1 2 3", "4 5 6 7 8 9..."}},
+	}
+
+	for _, tt := range tests {
+		lines := wrapText(tt.in, font, width, 3)
+		if !reflect.DeepEqual(lines, tt.lines) {
+			t.Errorf(`
+%q
+Expected: %q
+Got       %q`, tt.in, tt.lines, lines)
+		}
+		for _, l := range lines {
+			if font.Width(l) > width && len(l) > 1 {
+				t.Errorf("%q: line %q is too wide", tt.in, l)
+			}
+		}
+	}
+}
+
+func TestPlotSubtitle(t *testing.T) {
+	for _, title := range []string{"Title", ""} {
+		opts := PlotOptions{Format: "png", Title: title, Subtitle: `sum by (job) (rate(http_requests_total{job=~"api|web"}[5m]))`}
+		if _, err := PlotWithOptions(testMatrix(2), opts); err != nil {
+			t.Errorf("%q: plot failed unexpectedly: %v", title, err)
+		}
+	}
+}
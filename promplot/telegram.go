@@ -0,0 +1,79 @@
+package promplot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// Base URL of the Telegram Bot API
+var telegramAPI = "https://api.telegram.org"
+
+// Telegram sends a plot as photo to a Telegram chat using a bot.
+// The title is used as caption of the photo.
+// Telegram only accepts images like jpg and png as photos.
+// Errors wrap ErrUpload.
+func Telegram(token, chatID, title string, plot io.WriterTo) error {
+	return TelegramContext(context.Background(), token, chatID, title, plot)
+}
+
+// TelegramContext sends a plot to a Telegram chat like Telegram.
+// The request is aborted when ctx is canceled or its deadline is exceeded.
+func TelegramContext(ctx context.Context, token, chatID, title string, plot io.WriterTo) (err error) {
+	defer wrap(ErrUpload, &err)
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.WriteField("chat_id", chatID); err != nil {
+		return fmt.Errorf("failed to write chat id: %w", err)
+	}
+	if err := mw.WriteField("caption", title); err != nil {
+		return fmt.Errorf("failed to write caption: %w", err)
+	}
+	photo, err := mw.CreateFormFile("photo", "promplot")
+	if err != nil {
+		return fmt.Errorf("failed to create photo field: %w", err)
+	}
+	if _, err = plot.WriteTo(photo); err != nil {
+		return fmt.Errorf("failed to write plot to request: %w", err)
+	}
+	if err = mw.Close(); err != nil {
+		return fmt.Errorf("failed to close request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, telegramAPI+"/bot"+token+"/sendPhoto", &body)
+	if err != nil {
+		return fmt.Errorf("failed to create telegram request: %v", redact(err, token))
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("sending photo exceeded the deadline: %v", redact(err, token))
+		}
+		// Error contains the URL, don't leak the token
+		return fmt.Errorf("failed to send photo: %v", redact(err, token))
+	}
+	defer res.Body.Close()
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode telegram response (status %s): %w", res.Status, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram api error: %s", result.Description)
+	}
+	return nil
+}
+
+// redact removes a secret from an error message.
+func redact(err error, secret string) string {
+	return strings.Replace(err.Error(), secret, "***", -1)
+}
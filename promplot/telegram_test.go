@@ -0,0 +1,46 @@
+package promplot
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTelegram(t *testing.T) {
+	var chatID, caption, path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		chatID = r.FormValue("chat_id")
+		caption = r.FormValue("caption")
+		if _, _, err := r.FormFile("photo"); err != nil {
+			t.Errorf("missing photo: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if chatID != "42" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	defer srv.Close()
+	telegramAPI = srv.URL
+	defer func() { telegramAPI = "https://api.telegram.org" }()
+
+	if err := Telegram("token", "42", "title", bytes.NewBufferString("plot")); err != nil {
+		t.Fatalf("sending failed unexpectedly: %v", err)
+	}
+	if path != "/bottoken/sendPhoto" {
+		t.Errorf("unexpected path: %s", path)
+	}
+	if caption != "title" {
+		t.Errorf("unexpected caption: %s", caption)
+	}
+
+	err := Telegram("token", "1", "title", bytes.NewBufferString("plot"))
+	if err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Errorf("expected api error description, got: %v", err)
+	}
+}
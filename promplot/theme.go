@@ -0,0 +1,53 @@
+package promplot
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+// Themes are the valid values for PlotOptions.Theme.
+// "light" are the default colors, "dark" is meant for dark dashboards and pages.
+var Themes = []string{"light", "dark"}
+
+type theme struct {
+	background, text, axis, grid color.Color
+	palette                      string
+}
+
+var themes = map[string]theme{
+	// Nil colors keep the defaults of gonum
+	"light": {palette: DefaultPalette},
+	"dark": {
+		background: color.NRGBA{R: 0x1e, G: 0x1e, B: 0x1e, A: 0xff},
+		text:       color.NRGBA{R: 0xd4, G: 0xd4, B: 0xd4, A: 0xff},
+		axis:       color.NRGBA{R: 0xa0, G: 0xa0, B: 0xa0, A: 0xff},
+		grid:       color.NRGBA{R: 0x40, G: 0x40, B: 0x40, A: 0xff},
+		// Pastel colors have enough contrast to the dark background
+		palette: "Set2",
+	},
+}
+
+// applyTheme sets all colors and the palette of opts which are not set yet.
+func applyTheme(opts PlotOptions) (PlotOptions, error) {
+	t, ok := themes[opts.Theme]
+	if !ok {
+		return opts, fmt.Errorf("unknown theme %q, valid themes are: %s", opts.Theme, strings.Join(Themes, ", "))
+	}
+	if opts.Background == nil {
+		opts.Background = t.background
+	}
+	if opts.TextColor == nil {
+		opts.TextColor = t.text
+	}
+	if opts.AxisColor == nil {
+		opts.AxisColor = t.axis
+	}
+	if opts.GridColor == nil {
+		opts.GridColor = t.grid
+	}
+	if opts.Palette == "" {
+		opts.Palette = t.palette
+	}
+	return opts, nil
+}
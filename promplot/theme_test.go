@@ -0,0 +1,61 @@
+package promplot
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestApplyTheme(t *testing.T) {
+	light, err := PlotOptions{Format: "png", Theme: "light"}.withDefaults()
+	if err != nil {
+		t.Fatalf("light theme failed unexpectedly: %v", err)
+	}
+	defaults, err := PlotOptions{Format: "png"}.withDefaults()
+	if err != nil {
+		t.Fatalf("defaults failed unexpectedly: %v", err)
+	}
+	if light.Palette != defaults.Palette || light.Background != nil || light.TextColor != nil || light.AxisColor != nil || light.GridColor != nil {
+		t.Errorf("light theme should match the defaults, got %+v", light)
+	}
+
+	dark, err := applyTheme(PlotOptions{Theme: "dark"})
+	if err != nil {
+		t.Fatalf("dark theme failed unexpectedly: %v", err)
+	}
+	if dark.Background == nil || dark.TextColor == nil || dark.AxisColor == nil || dark.GridColor == nil || dark.Palette == DefaultPalette {
+		t.Errorf("dark theme should set all colors and the palette, got %+v", dark)
+	}
+
+	// Explicit options take precedence
+	custom, err := applyTheme(PlotOptions{Theme: "dark", Background: color.White, Palette: "Set1"})
+	if err != nil {
+		t.Fatalf("dark theme failed unexpectedly: %v", err)
+	}
+	if custom.Background != color.White || custom.Palette != "Set1" || custom.TextColor != dark.TextColor {
+		t.Errorf("explicit options should be kept, got %+v", custom)
+	}
+
+	if _, err := applyTheme(PlotOptions{Theme: "blue"}); err == nil {
+		t.Error("unknown theme should be invalid")
+	}
+}
+
+func TestPlotTheme(t *testing.T) {
+	opts, err := PlotOptions{Format: "png", Theme: "dark", Grid: true}.withDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := newPlot(testMatrix(1), opts)
+	if err != nil {
+		t.Fatalf("plot failed unexpectedly: %v", err)
+	}
+	if p.Title.Color != opts.TextColor || p.X.Tick.Label.Color != opts.TextColor || p.Legend.Color != opts.TextColor {
+		t.Error("text should use the text color of the theme")
+	}
+	if p.X.Color != opts.AxisColor || p.Y.Tick.Color != opts.AxisColor {
+		t.Error("axes should use the axis color of the theme")
+	}
+	if _, err := PlotWithOptions(testMatrix(1), PlotOptions{Format: "png", Theme: "dark", Grid: true}); err != nil {
+		t.Errorf("plot failed unexpectedly: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package promplot
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/plot"
+)
+
+// Units are the valid values for UnitTicks.
+var Units = []string{"bytes", "seconds", "percent", "si"}
+
+type scale struct {
+	factor float64
+	suffix string
+}
+
+var (
+	siScales = []scale{
+		{1e12, "T"}, {1e9, "G"}, {1e6, "M"}, {1e3, "k"}, {1, ""}, {1e-3, "m"}, {1e-6, "µ"}, {1e-9, "n"},
+	}
+	byteScales = []scale{
+		{1 << 50, "PiB"}, {1 << 40, "TiB"}, {1 << 30, "GiB"}, {1 << 20, "MiB"}, {1 << 10, "KiB"}, {1, "B"},
+	}
+	secondScales = []scale{
+		{86400, "d"}, {3600, "h"}, {60, "min"}, {1, "s"}, {1e-3, "ms"}, {1e-6, "µs"}, {1e-9, "ns"},
+	}
+)
+
+// UnitTicks is a plot.Ticker that formats the labels of the default ticks with a unit suffix.
+// Supported units are:
+//   - "si": metric prefixes, e.g. 1.5G
+//   - "bytes": binary prefixes, e.g. 1.5GiB
+//   - "seconds": time units, e.g. 200ms
+//   - "percent": ratios between 0 and 1 as percentage, e.g. 80%
+type UnitTicks struct {
+	Unit string
+}
+
+var _ plot.Ticker = UnitTicks{}
+
+// Ticks returns the default ticks with formatted labels.
+func (u UnitTicks) Ticks(min, max float64) []plot.Tick {
+	ticks := plot.DefaultTicks{}.Ticks(min, max)
+	for i, t := range ticks {
+		if t.Label != "" {
+			ticks[i].Label = FormatUnit(t.Value, u.Unit)
+		}
+	}
+	return ticks
+}
+
+// FormatUnit formats a value with a suffix of the given unit.
+// Unknown units are formatted without suffix.
+func FormatUnit(v float64, unit string) string {
+	switch unit {
+	case "si":
+		return formatScaled(v, siScales)
+	case "bytes":
+		return formatScaled(v, byteScales)
+	case "seconds":
+		return formatScaled(v, secondScales)
+	case "percent":
+		return formatNumber(v*100) + "%"
+	}
+	return formatNumber(v)
+}
+
+func formatScaled(v float64, scales []scale) string {
+	if v == 0 {
+		return "0"
+	}
+	abs := math.Abs(v)
+	for _, s := range scales {
+		if abs >= s.factor {
+			return formatNumber(v/s.factor) + s.suffix
+		}
+	}
+	s := scales[len(scales)-1]
+	return formatNumber(v/s.factor) + s.suffix
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+func validUnit(unit string) error {
+	for _, u := range Units {
+		if unit == u {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown unit %q, valid units are: %s", unit, strings.Join(Units, ", "))
+}
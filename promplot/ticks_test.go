@@ -0,0 +1,48 @@
+package promplot
+
+import "testing"
+
+func TestFormatUnit(t *testing.T) {
+	tests := []struct {
+		value     float64
+		unit      string
+		formatted string
+	}{
+		{value: 1500000000, unit: "", formatted: "1500000000"},
+		{value: 1500000000, unit: "si", formatted: "1.5G"},
+		{value: 200, unit: "si", formatted: "200"},
+		{value: 0.002, unit: "si", formatted: "2m"},
+		{value: -2500, unit: "si", formatted: "-2.5k"},
+		{value: 0, unit: "si", formatted: "0"},
+		{value: 1536, unit: "bytes", formatted: "1.5KiB"},
+		{value: 3 << 30, unit: "bytes", formatted: "3GiB"},
+		{value: 512, unit: "bytes", formatted: "512B"},
+		{value: 0.2, unit: "seconds", formatted: "200ms"},
+		{value: 90, unit: "seconds", formatted: "1.5min"},
+		{value: 7200, unit: "seconds", formatted: "2h"},
+		{value: 0.8, unit: "percent", formatted: "80%"},
+		{value: 1, unit: "percent", formatted: "100%"},
+	}
+
+	for i, tt := range tests {
+		formatted := FormatUnit(tt.value, tt.unit)
+		if formatted != tt.formatted {
+			t.Errorf(`
+%d.
+Input:    %v %s
+Expected: %s
+Got       %s`, i, tt.value, tt.unit, tt.formatted, formatted)
+		}
+	}
+}
+
+func TestUnitTicks(t *testing.T) {
+	for _, tick := range (UnitTicks{Unit: "bytes"}).Ticks(0, 4<<20) {
+		if tick.Label == "" {
+			continue
+		}
+		if tick.Label != "0" && tick.Label[len(tick.Label)-1] != 'B' {
+			t.Errorf("unexpected tick label: %s", tick.Label)
+		}
+	}
+}
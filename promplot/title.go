@@ -0,0 +1,89 @@
+package promplot
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// TitleData holds the values that can be used in title templates.
+type TitleData struct {
+	// Query is the PromQL query.
+	Query string
+	// Range is the time range of the query.
+	Range model.Duration
+	// Time is the end time of the query.
+	Time time.Time
+	// Step is the resolution of the query, see QueryStep.
+	Step model.Duration
+}
+
+// ExpandTitle executes a title as text/template with the given data,
+// for example "{{.Query}} over last {{.Range}}" or "resolution: {{.Step}}".
+func ExpandTitle(title string, data TitleData) (string, error) {
+	t, err := template.New("title").Parse(title)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse title: %w", err)
+	}
+	var b strings.Builder
+	if err = t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to execute title: %w", err)
+	}
+	return b.String(), nil
+}
+
+func validTitleAlign(align string) error {
+	for _, a := range TitleAligns {
+		if align == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown title alignment %q, valid alignments are: %s", align, strings.Join(TitleAligns, ", "))
+}
+
+// textAnchor returns the alignment and X position for drawing text in c aligned like align.
+func textAnchor(c draw.Canvas, align string) (draw.XAlignment, vg.Length) {
+	switch align {
+	case "left":
+		return draw.XLeft, c.Min.X
+	case "right":
+		return draw.XRight, c.Max.X
+	}
+	return draw.XCenter, c.Center().X
+}
+
+// drawPlot draws p onto c with title and subtitle aligned like opts.TitleAlign.
+func drawPlot(c draw.Canvas, p *plot.Plot, opts PlotOptions) {
+	if opts.TitleAlign != "center" && p.Title.Text != "" {
+		// The plot always centers the title, so it is drawn separately
+		if p.BackgroundColor != nil {
+			c.SetColor(p.BackgroundColor)
+			c.Fill(c.Rectangle.Path())
+			p.BackgroundColor = nil
+		}
+		sty := p.Title.TextStyle
+		var x vg.Length
+		sty.XAlign, x = textAnchor(c, opts.TitleAlign)
+		c.FillText(sty, vg.Point{X: x, Y: c.Max.Y - sty.Font.Extents().Descent}, p.Title.Text)
+		_, h, d := p.Title.Handler.Box(p.Title.Text, p.Title.Font)
+		c.Max.Y -= h + d
+		if opts.Subtitle != "" {
+			// The subtitle is drawn with the padding below it
+			c.Max.Y -= subtitleGap
+		} else {
+			c.Max.Y -= p.Title.Padding
+		}
+		p.Title.Text = ""
+	}
+	if opts.Subtitle != "" {
+		drawWithSubtitle(c, p, opts.Subtitle, opts.TitleAlign)
+		return
+	}
+	p.Draw(c)
+}
@@ -0,0 +1,151 @@
+package promplot
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"gonum.org/v1/plot/vg"
+)
+
+func TestExpandTitle(t *testing.T) {
+	data := TitleData{
+		Query: "node_cpu",
+		Range: model.Duration(6 * time.Hour),
+		Time:  time.Date(2024, 1, 2, 15, 4, 0, 0, time.UTC),
+		Step:  model.Duration(30 * time.Second),
+	}
+	tests := []struct {
+		title    string
+		expanded string
+		invalid  bool
+	}{
+		{title: "Prometheus metrics", expanded: "Prometheus metrics"},
+		{title: "{{.Query}} over last {{.Range}}", expanded: "node_cpu over last 6h"},
+		{title: `{{.Query}} (ending {{.Time.Format "2006-01-02 15:04"}})`, expanded: "node_cpu (ending 2024-01-02 15:04)"},
+		{title: "{{.Query}} (resolution: {{.Step}})", expanded: "node_cpu (resolution: 30s)"},
+		{title: "{{.Query", invalid: true},
+		{title: "{{.Unknown}}", invalid: true},
+	}
+
+	for i, tt := range tests {
+		expanded, err := ExpandTitle(tt.title, data)
+		if err != nil {
+			if !tt.invalid {
+				t.Errorf("expanding '%s' failed unexpectedly: %v", tt.title, err)
+			}
+			continue
+		}
+		if tt.invalid {
+			t.Errorf("expanding '%s' should have failed", tt.title)
+			continue
+		}
+		if expanded != tt.expanded {
+			t.Errorf(`
+%d.
+Input:    %s
+Expected: %s
+Got       %s`, i, tt.title, tt.expanded, expanded)
+		}
+	}
+}
+
+// renderTitle renders a plot of testMatrix(1) with opts.
+func renderTitle(t *testing.T, opts PlotOptions) []byte {
+	t.Helper()
+	plot, err := PlotWithOptions(testMatrix(1), opts)
+	if err != nil {
+		t.Fatalf("plot failed unexpectedly: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := plot.WriteTo(&buf); err != nil {
+		t.Fatalf("writing failed unexpectedly: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// titleBounds returns the horizontal extent of non-white pixels in the top rows of a PNG plot.
+func titleBounds(t *testing.T, opts PlotOptions) (minX, maxX, width int) {
+	t.Helper()
+	opts.Format = "png"
+	opts.Legend = "none"
+	img, err := png.Decode(bytes.NewReader(renderTitle(t, opts)))
+	if err != nil {
+		t.Fatalf("invalid PNG: %v", err)
+	}
+	b := img.Bounds()
+	minX, maxX = b.Max.X, b.Min.X
+	// The title is drawn within the margin and its font size from the top
+	for y := b.Min.Y; y < b.Min.Y+b.Dy()/8; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if r, g, b, _ := img.At(x, y).RGBA(); r < 0x8000 && g < 0x8000 && b < 0x8000 {
+				if x < minX {
+					minX = x
+				}
+				if x > maxX {
+					maxX = x
+				}
+			}
+		}
+	}
+	if minX > maxX {
+		t.Fatalf("%s: no title found", opts.TitleAlign)
+	}
+	return minX, maxX, b.Dx()
+}
+
+func TestPlotTitleAlign(t *testing.T) {
+	// Default margin of about 23 pixels and a few pixels of space around the letters
+	const edge = 30
+	minX, maxX, width := titleBounds(t, PlotOptions{Title: "Title", TitleAlign: "left"})
+	if minX > edge || maxX > width/2 {
+		t.Errorf("left: title should be at the left edge, found at %d-%d of %d", minX, maxX, width)
+	}
+	minX, maxX, width = titleBounds(t, PlotOptions{Title: "Title", TitleAlign: "right"})
+	if maxX < width-edge || minX < width/2 {
+		t.Errorf("right: title should be at the right edge, found at %d-%d of %d", minX, maxX, width)
+	}
+	for _, align := range []string{"", "center"} {
+		minX, maxX, width = titleBounds(t, PlotOptions{Title: "Title", TitleAlign: align})
+		if d := (minX+maxX)/2 - width/2; d < -3 || d > 3 {
+			t.Errorf("%q: title should be centered, found at %d-%d of %d", align, minX, maxX, width)
+		}
+	}
+	for _, align := range TitleAligns {
+		opts := PlotOptions{Format: "png", Title: "Title", Subtitle: "up", TitleAlign: align}
+		if _, err := PlotWithOptions(testMatrix(1), opts); err != nil {
+			t.Errorf("%s: plot with subtitle failed unexpectedly: %v", align, err)
+		}
+	}
+	if _, err := PlotWithOptions(testMatrix(1), PlotOptions{Format: "png", TitleAlign: "top"}); err == nil {
+		t.Error("unknown alignment should fail")
+	}
+}
+
+func TestPlotTitlePadding(t *testing.T) {
+	defaults := renderTitle(t, PlotOptions{Format: "png", Title: "Title"})
+	if !bytes.Equal(defaults, renderTitle(t, PlotOptions{Format: "png", Title: "Title", TitleAlign: "center", TitlePadding: DefaultTitlePadding})) {
+		t.Error("defaults should be centered with DefaultTitlePadding")
+	}
+	if bytes.Equal(defaults, renderTitle(t, PlotOptions{Format: "png", Title: "Title", TitlePadding: 5 * vg.Millimeter})) {
+		t.Error("title padding should change the plot")
+	}
+	if _, err := PlotWithOptions(testMatrix(1), PlotOptions{Format: "png", TitlePadding: -vg.Millimeter}); err == nil {
+		t.Error("negative padding should fail")
+	}
+}
+
+func TestPlotHideTitle(t *testing.T) {
+	hidden := renderTitle(t, PlotOptions{Format: "svg", Title: "Title", HideTitle: true})
+	if bytes.Contains(hidden, []byte(">Title<")) {
+		t.Error("hidden title should not be drawn")
+	}
+	if !bytes.Equal(hidden, renderTitle(t, PlotOptions{Format: "svg"})) {
+		t.Error("hidden title should not reserve space")
+	}
+	if bytes.Equal(hidden, renderTitle(t, PlotOptions{Format: "svg", Title: "Title"})) {
+		t.Error("title should change the plot")
+	}
+}
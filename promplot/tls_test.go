@@ -0,0 +1,144 @@
+package promplot
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadCertPool(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(matrixResponse))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "promplot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ca := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty.pem")
+	if err := ioutil.WriteFile(empty, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pool, err := LoadCertPool(ca)
+	if err != nil {
+		t.Fatalf("loading CA failed unexpectedly: %v", err)
+	}
+	cfg := MetricsConfig{TLSConfig: &tls.Config{RootCAs: pool}}
+	if _, err := MetricsWithConfig(context.Background(), srv.URL, "up", time.Unix(1500000060, 0), time.Minute, 1, cfg); err != nil {
+		t.Errorf("query with CA failed unexpectedly: %v", err)
+	}
+
+	for _, path := range []string{empty, filepath.Join(dir, "missing.pem")} {
+		if _, err := LoadCertPool(path); err == nil {
+			t.Errorf("loading '%s' should have failed", path)
+		}
+	}
+}
+
+// writeKeyPair generates a self-signed client certificate and writes it with its key to dir.
+func writeKeyPair(t *testing.T, dir, name string) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+"-key.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return cert, certFile, keyFile
+}
+
+func TestLoadKeyPair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "promplot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	client, certFile, keyFile := writeKeyPair(t, dir, "client")
+	_, _, otherKey := writeKeyPair(t, dir, "other")
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(matrixResponse))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(client)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	// Server is verified with its own certificate as CA
+	serverCA := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(serverCA, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	pool, err := LoadCertPool(serverCA)
+	if err != nil {
+		t.Fatalf("loading CA failed unexpectedly: %v", err)
+	}
+
+	cert, err := LoadKeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("loading key pair failed unexpectedly: %v", err)
+	}
+	queryTime := time.Unix(1500000060, 0)
+	cfg := MetricsConfig{TLSConfig: &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{cert}}}
+	if _, err := MetricsWithConfig(context.Background(), srv.URL, "up", queryTime, time.Minute, 1, cfg); err != nil {
+		t.Errorf("query with client certificate failed unexpectedly: %v", err)
+	}
+	cfg = MetricsConfig{TLSConfig: &tls.Config{RootCAs: pool}}
+	if _, err := MetricsWithConfig(context.Background(), srv.URL, "up", queryTime, time.Minute, 1, cfg); err == nil {
+		t.Error("query without client certificate should have failed")
+	}
+
+	if _, err := LoadKeyPair(certFile, otherKey); err == nil {
+		t.Error("loading mismatched key pair should have failed")
+	}
+}
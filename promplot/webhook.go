@@ -0,0 +1,126 @@
+package promplot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// WebhookConfig holds optional settings for posting to a webhook.
+type WebhookConfig struct {
+	// Headers are added to the request, e.g. for authentication.
+	Headers http.Header
+	// ContentType of the plot, e.g. ContentType("png"). Defaults to detecting it from the content.
+	// A Content-Type in Headers takes precedence for plain requests.
+	ContentType string
+	// Multipart sends the plot as file field "file" and the title as field "title" of a multipart form
+	// instead of sending the plot as request body.
+	Multipart bool
+	// Filename of the plot in multipart forms. Defaults to "promplot".
+	Filename string
+}
+
+// Maximum length of response bodies included in errors
+const webhookErrorBody = 512
+
+// Webhook posts a plot to an HTTP endpoint.
+// By default the plot is sent as request body and the title in the TitleHeader header.
+// Responses with a status other than 2xx are errors which include the start of the response body.
+// Errors wrap ErrUpload.
+func Webhook(url, title string, plot io.WriterTo, cfg WebhookConfig) error {
+	return WebhookContext(context.Background(), url, title, plot, cfg)
+}
+
+// WebhookContext posts a plot to an HTTP endpoint like Webhook.
+// The request is aborted when ctx is canceled or its deadline is exceeded.
+func WebhookContext(ctx context.Context, url, title string, plot io.WriterTo, cfg WebhookConfig) (err error) {
+	defer wrap(ErrUpload, &err)
+	var body bytes.Buffer
+	contentType := cfg.ContentType
+	if cfg.Multipart {
+		contentType, err = webhookForm(&body, title, plot, cfg)
+		if err != nil {
+			return err
+		}
+	} else {
+		if _, err := plot.WriteTo(&body); err != nil {
+			return fmt.Errorf("failed to write plot to request: %w", err)
+		}
+		if contentType == "" {
+			contentType = http.DetectContentType(body.Bytes())
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if !cfg.Multipart && title != "" {
+		// Header values cannot contain line breaks
+		req.Header.Set(TitleHeader, strings.Join(strings.Fields(title), " "))
+	}
+	for name, values := range cfg.Headers {
+		if cfg.Multipart && http.CanonicalHeaderKey(name) == "Content-Type" {
+			// The boundary of the form is required
+			continue
+		}
+		req.Header.Del(name)
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("webhook request exceeded the deadline: %v", redact(err, url))
+		}
+		// Error contains the URL which might include a token
+		return fmt.Errorf("failed to send webhook request: %v", redact(err, url))
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		data, _ := ioutil.ReadAll(io.LimitReader(res.Body, webhookErrorBody))
+		return fmt.Errorf("webhook failed with %s: %s", res.Status, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// TitleHeader contains the title of a plot sent with Webhook.
+const TitleHeader = "X-Promplot-Title"
+
+// webhookForm writes title and plot as multipart form to w and returns its content type.
+func webhookForm(w io.Writer, title string, plot io.WriterTo, cfg WebhookConfig) (string, error) {
+	mw := multipart.NewWriter(w)
+	if err := mw.WriteField("title", title); err != nil {
+		return "", fmt.Errorf("failed to write title: %w", err)
+	}
+	filename := cfg.Filename
+	if filename == "" {
+		filename = "promplot"
+	}
+	h := make(map[string][]string)
+	h["Content-Disposition"] = []string{fmt.Sprintf(`form-data; name="file"; filename="%s"`, strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(filename))}
+	if cfg.ContentType != "" {
+		h["Content-Type"] = []string{cfg.ContentType}
+	} else {
+		h["Content-Type"] = []string{"application/octet-stream"}
+	}
+	file, err := mw.CreatePart(h)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file field: %w", err)
+	}
+	if _, err := plot.WriteTo(file); err != nil {
+		return "", fmt.Errorf("failed to write plot to request: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return "", fmt.Errorf("failed to close request body: %w", err)
+	}
+	return mw.FormDataContentType(), nil
+}
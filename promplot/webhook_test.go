@@ -0,0 +1,103 @@
+package promplot
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhook(t *testing.T) {
+	var contentType, title, auth, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("invalid token\n" + strings.Repeat("x", 2*webhookErrorBody)))
+			return
+		}
+		contentType, auth = r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		if strings.HasPrefix(contentType, "multipart/form-data") {
+			title = r.FormValue("title")
+			f, header, err := r.FormFile("file")
+			if err != nil {
+				t.Errorf("missing file: %v", err)
+				return
+			}
+			data, _ := ioutil.ReadAll(f)
+			contentType, body = header.Filename+" "+header.Header.Get("Content-Type"), string(data)
+			return
+		}
+		title = r.Header.Get(TitleHeader)
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer srv.Close()
+
+	headers := http.Header{"Authorization": {"Bearer token"}}
+	tests := []struct {
+		name        string
+		cfg         WebhookConfig
+		contentType string
+		title       string
+	}{
+		{
+			name:        "detected",
+			cfg:         WebhookConfig{Headers: headers},
+			contentType: "image/png",
+			title:       "Load per host",
+		},
+		{
+			name:        "configured",
+			cfg:         WebhookConfig{Headers: headers, ContentType: "image/svg+xml"},
+			contentType: "image/svg+xml",
+			title:       "Load per host",
+		},
+		{
+			name:        "header overrides",
+			cfg:         WebhookConfig{Headers: http.Header{"Authorization": {"Bearer token"}, "Content-Type": {"application/octet-stream"}}, ContentType: "image/png"},
+			contentType: "application/octet-stream",
+			title:       "Load per host",
+		},
+		{
+			name:        "multipart",
+			cfg:         WebhookConfig{Headers: http.Header{"Authorization": {"Bearer token"}, "Content-Type": {"text/plain"}}, Multipart: true, ContentType: "image/png", Filename: "up.png"},
+			contentType: "up.png image/png",
+			title:       "Load\nper host",
+		},
+	}
+	plot := "\x89PNG\r\n\x1a\nplot"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentType, title, auth, body = "", "", "", ""
+			if err := Webhook(srv.URL, "Load\nper host", bytes.NewBufferString(plot), tt.cfg); err != nil {
+				t.Fatalf("posting failed unexpectedly: %v", err)
+			}
+			if contentType != tt.contentType {
+				t.Errorf("expected content type %q, got %q", tt.contentType, contentType)
+			}
+			if title != tt.title {
+				t.Errorf("expected title %q, got %q", tt.title, title)
+			}
+			if auth != "Bearer token" {
+				t.Errorf("missing header, got: %q", auth)
+			}
+			if body != plot {
+				t.Errorf("unexpected body: %q", body)
+			}
+		})
+	}
+
+	err := Webhook(srv.URL+"/fail", "Load", bytes.NewBufferString(plot), WebhookConfig{})
+	if err == nil || !strings.Contains(err.Error(), "403 Forbidden: invalid token") {
+		t.Errorf("expected error with response body, got: %v", err)
+	}
+	if err != nil && len(err.Error()) > 2*webhookErrorBody {
+		t.Errorf("response body should be truncated, got %d bytes", len(err.Error()))
+	}
+	if !errors.Is(err, ErrUpload) {
+		t.Errorf("expected upload error, got: %v", err)
+	}
+}